@@ -5,4 +5,5 @@ go 1.15
 require (
 	github.com/sirupsen/logrus v1.6.0
 	github.com/stretchr/testify v1.2.2
+	gopkg.in/yaml.v3 v3.0.1
 )
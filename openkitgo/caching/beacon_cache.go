@@ -1,28 +1,190 @@
 package caching
 
 import (
-	log "github.com/sirupsen/logrus"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
-type BeaconCache struct {
-	log              *log.Logger
+// BeaconCache stores the serialized events and actions of every beacon until they are sent.
+// NewBeaconCache returns the default implementation that keeps the data in memory, other storage backends can be
+// passed to OpenKitBuilder.WithBeaconCache and should pass the conformance tests in the cachetest package.
+type BeaconCache interface {
+	AddEventData(key BeaconKey, timestamp time.Time, data string)
+	AddActionData(key BeaconKey, timestamp time.Time, data string)
+	DeleteCacheEntry(key BeaconKey)
+
+	// PrepareDataForSending moves the data of key aside, data added afterwards is not part of the chunks
+	PrepareDataForSending(key BeaconKey)
+	HasDataForSending(key BeaconKey) bool
+	// GetNextBeaconChunk appends prepared records to chunkPrefix, separated by delimiter, while the chunk is
+	// shorter than maxSize and marks them as sent
+	GetNextBeaconChunk(key BeaconKey, chunkPrefix string, maxSize int, delimiter rune) string
+	// RemoveChunkedData removes the records marked by GetNextBeaconChunk
+	RemoveChunkedData(key BeaconKey)
+	// ResetChunkedData puts all prepared records back in front of the data added since
+	ResetChunkedData(key BeaconKey)
+
+	GetBeaconKeys() []BeaconKey
+	// GetNumBytesInCache is the size of the records that are not prepared for sending
+	GetNumBytesInCache() int64
+	IsEmpty(key BeaconKey) bool
+
+	// GetEvictableRecords returns the records of key that are not prepared for sending in the order
+	// EvictRecordsByNumber removes them, oldest first
+	GetEvictableRecords(key BeaconKey) []RecordInfo
+	// EvictRecordsByAge removes the records of key that are not prepared for sending and not newer than
+	// timestamp and returns how many records and bytes were removed
+	EvictRecordsByAge(key BeaconKey, timestamp time.Time) (int, int64)
+	// EvictRecordsByNumber removes up to numRecords of the oldest records of key that are not prepared for sending
+	// and returns how many records and bytes were removed
+	EvictRecordsByNumber(key BeaconKey, numRecords int) (int, int64)
+	// RemoveRecordsIf removes the records of key for which remove returns true and returns how many were removed
+	RemoveRecordsIf(key BeaconKey, remove func(data string) bool) int
+}
+
+// MetadataStore is implemented by caches that keep data across restarts. The metadata of a beacon is what
+// OpenKit needs to send its records after a restart.
+type MetadataStore interface {
+	// SetMetadata stores data with the records of key until the entry is deleted
+	SetMetadata(key BeaconKey, data string)
+	// GetMetadata returns the data stored with SetMetadata, "" if there is none
+	GetMetadata(key BeaconKey) string
+}
+
+// SetMetadata stores data with the records of key if cache is a MetadataStore
+func SetMetadata(cache BeaconCache, key BeaconKey, data string) {
+	if store, ok := cache.(MetadataStore); ok {
+		store.SetMetadata(key, data)
+	}
+}
+
+// GetMetadata returns the metadata of key if cache is a MetadataStore, "" otherwise
+func GetMetadata(cache BeaconCache, key BeaconKey) string {
+	if store, ok := cache.(MetadataStore); ok {
+		return store.GetMetadata(key)
+	}
+	return ""
+}
+
+// Flusher is implemented by caches that buffer writes, OpenKit flushes them when it shuts down
+type Flusher interface {
+	Flush() error
+}
+
+type beaconCache struct {
+	log              log.Logger
 	mutex            sync.RWMutex
 	beacons          map[BeaconKey]*BeaconCacheEntry
 	cacheSizeInBytes int64 // Atomic
-	observers        []*chan bool
+	// store is nil for caches that only live in memory
+	store *segmentStore
 }
 
-func NewBeaconCache(log *log.Logger) *BeaconCache {
-	return &BeaconCache{
+func NewBeaconCache(log log.Logger) BeaconCache {
+	return newBeaconCache(log)
+}
+
+func newBeaconCache(log log.Logger) *beaconCache {
+	return &beaconCache{
 		log:     log,
 		beacons: map[BeaconKey]*BeaconCacheEntry{},
 	}
 }
 
-func (c *BeaconCache) AddEventData(key BeaconKey, timestamp time.Time, data string) {
+// NewPersistentBeaconCache returns a BeaconCache that also writes its records to dir and reloads the records a
+// previous process left there. Reloaded records older than the max record age are dropped and the oldest
+// records are evicted while the cache is above its upper memory boundary.
+// Files that are only partly readable are loaded up to the first malformed line and logged.
+func NewPersistentBeaconCache(logger log.Logger, dir string, config *configuration.BeaconCacheConfiguration, clock providers.Clock) (BeaconCache, error) {
+	c, err := newPersistentBeaconCache(logger, dir, config, clock)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CheckBeaconCacheDirectory returns the error NewPersistentBeaconCache would fail with because of dir, dir is created
+// if it does not exist
+func CheckBeaconCacheDirectory(dir string) error {
+	return checkSegmentDirectory(dir)
+}
+
+func newPersistentBeaconCache(logger log.Logger, dir string, config *configuration.BeaconCacheConfiguration, clock providers.Clock) (*beaconCache, error) {
+	store, err := newSegmentStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := newBeaconCache(logger)
+	c.store = store
+
+	entries, errs := store.load()
+	for _, err := range errs {
+		c.log.WithFields(log.Fields{"dir": dir, "error": err}).Warning("BeaconCache could not read all persisted records")
+	}
+	for key, entry := range entries {
+		c.beacons[key] = entry
+		c.cacheSizeInBytes += entry.totalNumBytes
+	}
+
+	minAllowedAge := clock.Now().Add(-config.MaxRecordAge)
+	for _, key := range c.GetBeaconKeys() {
+		c.EvictRecordsByAge(key, minAllowedAge)
+	}
+	NewSpaceEvictionStrategy(logger, c, config).execute(false)
+
+	c.log.WithFields(log.Fields{"dir": dir, "beacons": len(c.beacons), "bytes": c.GetNumBytesInCache()}).Info("BeaconCache loaded persisted records")
+	return c, nil
+}
+
+// persist runs write if the cache is persistent and logs its error, callers hold the mutex of the entry
+func (c *beaconCache) persist(key BeaconKey, write func(store *segmentStore) error) {
+	if c.store == nil {
+		return
+	}
+	if err := write(c.store); err != nil {
+		c.log.WithFields(log.Fields{"key": key.String(), "error": err}).Warning("BeaconCache could not persist records")
+	}
+}
+
+// rewrite replaces the persisted records of key after records were removed, callers hold the mutex of the entry
+func (c *beaconCache) rewrite(key BeaconKey, entry *BeaconCacheEntry) {
+	c.persist(key, func(store *segmentStore) error {
+		return store.rewrite(key, entry)
+	})
+}
+
+// SetMetadata stores data with the records of key, persistent caches reload it together with the records
+func (c *beaconCache) SetMetadata(key BeaconKey, data string) {
+	entry := c.getCachedEntryOrInsert(key)
+
+	entry.mutex.Lock()
+	entry.metadata = data
+	c.persist(key, func(store *segmentStore) error {
+		return store.append(key, segmentKindMetadata, time.Unix(0, 0), data)
+	})
+	entry.mutex.Unlock()
+}
+
+// GetMetadata returns the data stored with SetMetadata, "" if there is none
+func (c *beaconCache) GetMetadata(key BeaconKey) string {
+	c.mutex.Lock()
+	entry := c.getCachedEntry(key)
+	c.mutex.Unlock()
+	if entry == nil {
+		return ""
+	}
+
+	entry.mutex.RLock()
+	defer entry.mutex.RUnlock()
+	return entry.metadata
+}
+
+func (c *beaconCache) AddEventData(key BeaconKey, timestamp time.Time, data string) {
 	c.log.WithFields(log.Fields{"key": key.String(), "data": data, "time": timestamp}).Debug("BeaconCache.AddEventData()")
 
 	entry := c.getCachedEntryOrInsert(key)
@@ -30,14 +192,15 @@ func (c *BeaconCache) AddEventData(key BeaconKey, timestamp time.Time, data stri
 
 	entry.mutex.Lock()
 	entry.addEventData(record)
+	c.persist(key, func(store *segmentStore) error {
+		return store.append(key, segmentKindEvent, timestamp, data)
+	})
 	entry.mutex.Unlock()
 
 	atomic.AddInt64(&c.cacheSizeInBytes, record.getDataSizeInBytes())
-
-	c.onDataAdded()
 }
 
-func (c *BeaconCache) AddActionData(key BeaconKey, timestamp time.Time, data string) {
+func (c *beaconCache) AddActionData(key BeaconKey, timestamp time.Time, data string) {
 	c.log.WithFields(log.Fields{"key": key.String(), "data": data, "time": timestamp}).Debug("BeaconCache.AddActionData()")
 
 	entry := c.getCachedEntryOrInsert(key)
@@ -45,15 +208,16 @@ func (c *BeaconCache) AddActionData(key BeaconKey, timestamp time.Time, data str
 
 	entry.mutex.Lock()
 	entry.addActionData(record)
+	c.persist(key, func(store *segmentStore) error {
+		return store.append(key, segmentKindAction, timestamp, data)
+	})
 	entry.mutex.Unlock()
 
 	atomic.AddInt64(&c.cacheSizeInBytes, record.getDataSizeInBytes())
 
-	c.onDataAdded()
-
 }
 
-func (c *BeaconCache) getCachedEntryOrInsert(key BeaconKey) *BeaconCacheEntry {
+func (c *beaconCache) getCachedEntryOrInsert(key BeaconKey) *BeaconCacheEntry {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	entry := c.getCachedEntry(key)
@@ -67,22 +231,12 @@ func (c *BeaconCache) getCachedEntryOrInsert(key BeaconKey) *BeaconCacheEntry {
 	return entry
 }
 
-func (c *BeaconCache) getCachedEntry(key BeaconKey) *BeaconCacheEntry {
+func (c *beaconCache) getCachedEntry(key BeaconKey) *BeaconCacheEntry {
 	return c.beacons[key]
 
 }
 
-func (c *BeaconCache) onDataAdded() {
-	c.notifyObservers()
-}
-
-func (c *BeaconCache) notifyObservers() {
-	for _, o := range c.observers {
-		*o <- true
-	}
-}
-
-func (c *BeaconCache) DeleteCacheEntry(key BeaconKey) {
+func (c *beaconCache) DeleteCacheEntry(key BeaconKey) {
 	c.log.WithFields(log.Fields{"key": key.String()}).Debug("BeaconCache.DeleteCacheEntry()")
 
 	var entry *BeaconCacheEntry
@@ -93,12 +247,17 @@ func (c *BeaconCache) DeleteCacheEntry(key BeaconKey) {
 	c.mutex.Unlock()
 
 	if entry != nil {
+		entry.mutex.Lock()
 		atomic.AddInt64(&c.cacheSizeInBytes, -1*entry.totalNumBytes)
+		c.persist(key, func(store *segmentStore) error {
+			return store.remove(key)
+		})
+		entry.mutex.Unlock()
 	}
 
 }
 
-func (c *BeaconCache) PrepareDataForSending(key BeaconKey) {
+func (c *beaconCache) PrepareDataForSending(key BeaconKey) {
 	c.mutex.Lock()
 	entry := c.getCachedEntry(key)
 	c.mutex.Unlock()
@@ -106,18 +265,23 @@ func (c *BeaconCache) PrepareDataForSending(key BeaconKey) {
 		return
 	}
 
-	if entry.needsDataCopyBeforeSending() {
-		entry.mutex.Lock()
-		numBytes := entry.totalNumBytes
-		entry.copyDataForSending()
+	entry.mutex.Lock()
+	if !entry.needsDataCopyBeforeSending() {
 		entry.mutex.Unlock()
-
-		atomic.AddInt64(&c.cacheSizeInBytes, -1*numBytes)
-
+		return
 	}
+	numBytes := entry.totalNumBytes
+	entry.copyDataForSending()
+	// the records about to be sent are on disk before the first chunk leaves
+	c.persist(key, func(store *segmentStore) error {
+		return store.sync(key)
+	})
+	entry.mutex.Unlock()
+
+	atomic.AddInt64(&c.cacheSizeInBytes, -1*numBytes)
 }
 
-func (c *BeaconCache) HasDataForSending(key BeaconKey) bool {
+func (c *beaconCache) HasDataForSending(key BeaconKey) bool {
 	c.mutex.Lock()
 	entry := c.getCachedEntry(key)
 	c.mutex.Unlock()
@@ -129,7 +293,7 @@ func (c *BeaconCache) HasDataForSending(key BeaconKey) bool {
 
 }
 
-func (c *BeaconCache) GetNextBeaconChunk(key BeaconKey, chunkPrefix string, maxSize int, delimiter rune) string {
+func (c *beaconCache) GetNextBeaconChunk(key BeaconKey, chunkPrefix string, maxSize int, delimiter rune) string {
 	c.mutex.Lock()
 	entry := c.getCachedEntry(key)
 	c.mutex.Unlock()
@@ -139,7 +303,7 @@ func (c *BeaconCache) GetNextBeaconChunk(key BeaconKey, chunkPrefix string, maxS
 	return entry.getChunk(chunkPrefix, maxSize, delimiter)
 }
 
-func (c *BeaconCache) RemoveChunkedData(key BeaconKey) {
+func (c *beaconCache) RemoveChunkedData(key BeaconKey) {
 	c.mutex.Lock()
 	entry := c.getCachedEntry(key)
 	c.mutex.Unlock()
@@ -148,9 +312,13 @@ func (c *BeaconCache) RemoveChunkedData(key BeaconKey) {
 	}
 	entry.mutex.Lock()
 	entry.removeDataMarkedForSending()
+	// the file is rewritten once after the last chunk, not after every chunk
+	if !entry.hasDataToSend() {
+		c.rewrite(key, entry)
+	}
 	entry.mutex.Unlock()
 }
-func (c *BeaconCache) ResetChunkedData(key BeaconKey) {
+func (c *beaconCache) ResetChunkedData(key BeaconKey) {
 	c.mutex.Lock()
 	entry := c.getCachedEntry(key)
 	c.mutex.Unlock()
@@ -167,11 +335,9 @@ func (c *BeaconCache) ResetChunkedData(key BeaconKey) {
 
 	atomic.AddInt64(&c.cacheSizeInBytes, numBytes)
 
-	c.onDataAdded()
-
 }
 
-func (c *BeaconCache) GetBeaconKeys() []BeaconKey {
+func (c *beaconCache) GetBeaconKeys() []BeaconKey {
 	var result []BeaconKey
 
 	c.mutex.Lock()
@@ -184,26 +350,64 @@ func (c *BeaconCache) GetBeaconKeys() []BeaconKey {
 
 }
 
-func (c *BeaconCache) evictRecordsByAge(key BeaconKey, timestamp time.Time) int {
+func (c *beaconCache) GetEvictableRecords(key BeaconKey) []RecordInfo {
 	c.mutex.Lock()
 	entry := c.getCachedEntry(key)
 	c.mutex.Unlock()
 	if entry == nil {
-		return 0
+		return nil
+	}
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+	return entry.evictableRecords()
+}
+
+func (c *beaconCache) EvictRecordsByAge(key BeaconKey, timestamp time.Time) (int, int64) {
+	c.mutex.Lock()
+	entry := c.getCachedEntry(key)
+	c.mutex.Unlock()
+	if entry == nil {
+		return 0, 0
 	}
 
-	numRecordsRemoved := 0
+	entry.mutex.Lock()
+	numRecordsRemoved, numBytesRemoved := entry.removeRecordsOlderThan(timestamp)
+	if numRecordsRemoved > 0 {
+		c.rewrite(key, entry)
+	}
+	entry.mutex.Unlock()
+
+	atomic.AddInt64(&c.cacheSizeInBytes, -numBytesRemoved)
+
+	c.log.WithFields(log.Fields{"key": key.String(), "timestamp": timestamp, "evicted": numRecordsRemoved}).Debug("BeaconCache.EvictRecordsByAge()")
+
+	return numRecordsRemoved, numBytesRemoved
+}
+
+func (c *beaconCache) EvictRecordsByNumber(key BeaconKey, numRecords int) (int, int64) {
+	c.mutex.Lock()
+	entry := c.getCachedEntry(key)
+	c.mutex.Unlock()
+	if entry == nil {
+		return 0, 0
+	}
 
 	entry.mutex.Lock()
-	numRecordsRemoved = entry.removeRecordsOlderThan(timestamp)
+	numRecordsRemoved, numBytesRemoved := entry.removeOldestRecords(numRecords)
+	if numBytesRemoved > 0 {
+		c.rewrite(key, entry)
+	}
 	entry.mutex.Unlock()
 
-	log.WithFields(log.Fields{"key": key.String(), "timestamp": timestamp, "evicted": numRecordsRemoved}).Debug("BeaconCache.evictRecordsByAge()")
+	atomic.AddInt64(&c.cacheSizeInBytes, -numBytesRemoved)
 
-	return numRecordsRemoved
+	c.log.WithFields(log.Fields{"key": key.String(), "numRecords": numRecords, "evicted": numRecordsRemoved}).Debug("BeaconCache.EvictRecordsByNumber()")
+
+	return numRecordsRemoved, numBytesRemoved
 }
 
-func (c *BeaconCache) evictRecordsByNumber(key BeaconKey, numRecords int) int {
+func (c *beaconCache) RemoveRecordsIf(key BeaconKey, remove func(data string) bool) int {
 	c.mutex.Lock()
 	entry := c.getCachedEntry(key)
 	c.mutex.Unlock()
@@ -211,22 +415,24 @@ func (c *BeaconCache) evictRecordsByNumber(key BeaconKey, numRecords int) int {
 		return 0
 	}
 
-	numRecordsRemoved := 0
-
 	entry.mutex.Lock()
-	numRecordsRemoved = entry.removeOldestRecords(numRecords)
+	numRecordsRemoved, numBytesRemoved := entry.removeRecordsIf(remove)
+	if numRecordsRemoved > 0 {
+		c.rewrite(key, entry)
+	}
 	entry.mutex.Unlock()
 
-	log.WithFields(log.Fields{"key": key.String(), "numRecords": numRecords, "evicted": numRecordsRemoved}).Debug("BeaconCache.evictRecordsByNumber()")
+	atomic.AddInt64(&c.cacheSizeInBytes, -numBytesRemoved)
+	c.log.WithFields(log.Fields{"key": key.String(), "removed": numRecordsRemoved}).Debug("BeaconCache.RemoveRecordsIf()")
 
 	return numRecordsRemoved
 }
 
-func (c *BeaconCache) getNumBytesInCache() int64 {
+func (c *beaconCache) GetNumBytesInCache() int64 {
 	return atomic.LoadInt64(&c.cacheSizeInBytes)
 }
 
-func (c *BeaconCache) IsEmpty(key BeaconKey) bool {
+func (c *beaconCache) IsEmpty(key BeaconKey) bool {
 	c.mutex.Lock()
 	entry := c.getCachedEntry(key)
 	c.mutex.Unlock()
@@ -240,6 +446,10 @@ func (c *BeaconCache) IsEmpty(key BeaconKey) bool {
 
 }
 
-func (c *BeaconCache) AddObservable(channel *chan bool) {
-	c.observers = append(c.observers, channel)
+// Flush writes the buffered records of a persistent cache to disk and closes its files, OpenKit calls it on shutdown
+func (c *beaconCache) Flush() error {
+	if c.store == nil {
+		return nil
+	}
+	return c.store.flush()
 }
@@ -8,7 +8,7 @@ import (
 
 func TestAddEventData(t *testing.T) {
 
-	c := NewBeaconCache(logger)
+	c := newBeaconCache(logger)
 	k := NewBeaconKey(1, 1)
 	c.AddEventData(k, time.Now(), "contents_1")
 
@@ -18,7 +18,7 @@ func TestAddEventData(t *testing.T) {
 
 func TestAddActionData(t *testing.T) {
 
-	c := NewBeaconCache(logger)
+	c := newBeaconCache(logger)
 	k := NewBeaconKey(1, 1)
 	c.AddActionData(k, time.Now(), "contents_2")
 
@@ -28,7 +28,7 @@ func TestAddActionData(t *testing.T) {
 
 func TestDeleteCacheEntry(t *testing.T) {
 
-	c := NewBeaconCache(logger)
+	c := newBeaconCache(logger)
 	k := NewBeaconKey(1, 1)
 	c.AddActionData(k, time.Now(), "contents_2")
 
@@ -40,3 +40,35 @@ func TestDeleteCacheEntry(t *testing.T) {
 	assert.Equal(t, 0, len(c.beacons))
 
 }
+
+func TestRemoveRecordsIf(t *testing.T) {
+
+	c := newBeaconCache(logger)
+	k := NewBeaconKey(1, 1)
+	c.AddEventData(k, time.Now(), "et=11&na=value")
+	c.AddEventData(k, time.Now(), "et=18")
+	c.AddActionData(k, time.Now(), "et=1&na=action")
+
+	removed := c.RemoveRecordsIf(k, func(data string) bool { return data != "et=18" })
+	assert.Equal(t, 2, removed)
+	assert.Equal(t, int64(10), c.cacheSizeInBytes)
+	assert.Equal(t, int64(10), c.getCachedEntry(k).totalNumBytes)
+
+	assert.Equal(t, 0, c.RemoveRecordsIf(NewBeaconKey(2, 1), func(data string) bool { return true }))
+}
+
+func TestResetChunkedData(t *testing.T) {
+
+	c := newBeaconCache(logger)
+	k := NewBeaconKey(1, 1)
+	c.AddEventData(k, time.Now(), "contents_1")
+
+	c.PrepareDataForSending(k)
+	assert.Equal(t, int64(0), c.cacheSizeInBytes)
+	c.GetNextBeaconChunk(k, "", 1024, '&')
+	c.ResetChunkedData(k)
+
+	assert.False(t, c.HasDataForSending(k))
+	assert.Equal(t, int64(20), c.cacheSizeInBytes)
+	assert.Equal(t, 1, len(c.getCachedEntry(k).eventData))
+}
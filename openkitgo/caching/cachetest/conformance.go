@@ -0,0 +1,287 @@
+// Package cachetest provides conformance tests for caching.BeaconCache implementations
+package cachetest
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// NewCacheFunc returns a new, empty cache for every test
+type NewCacheFunc func(t *testing.T) caching.BeaconCache
+
+// Run runs all conformance tests as subtests of t:
+//
+//	func TestMyCache(t *testing.T) {
+//		cachetest.Run(t, func(t *testing.T) caching.BeaconCache { return NewMyCache() })
+//	}
+func Run(t *testing.T, newCache NewCacheFunc) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, c caching.BeaconCache)
+	}{
+		{"AddData", testAddData},
+		{"KeysAreIndependent", testKeysAreIndependent},
+		{"ChunkContainsEventsBeforeActions", testChunkContainsEventsBeforeActions},
+		{"ChunkRespectsMaxSize", testChunkRespectsMaxSize},
+		{"DataAddedAfterPrepareIsNotSent", testDataAddedAfterPrepareIsNotSent},
+		{"ResetChunkedData", testResetChunkedData},
+		{"DeleteCacheEntry", testDeleteCacheEntry},
+		{"GetEvictableRecords", testGetEvictableRecords},
+		{"EvictRecordsByAge", testEvictRecordsByAge},
+		{"EvictRecordsByNumber", testEvictRecordsByNumber},
+		{"RemoveRecordsIf", testRemoveRecordsIf},
+		{"Metadata", testMetadata},
+		{"UnknownKeys", testUnknownKeys},
+		{"ConcurrentAdds", testConcurrentAdds},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, newCache(t))
+		})
+	}
+}
+
+var (
+	key      = caching.NewBeaconKey(1, 0)
+	otherKey = caching.NewBeaconKey(2, 0)
+	start    = time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func size(data ...string) int64 {
+	n := int64(0)
+	for _, d := range data {
+		n += int64(len(d)) * caching.CHAR_SIZE_BYTES
+	}
+	return n
+}
+
+func sendAll(c caching.BeaconCache, k caching.BeaconKey) string {
+	c.PrepareDataForSending(k)
+	chunk := c.GetNextBeaconChunk(k, "", 1<<20, '&')
+	c.RemoveChunkedData(k)
+	return chunk
+}
+
+func testAddData(t *testing.T, c caching.BeaconCache) {
+	assert.True(t, c.IsEmpty(key))
+	assert.Equal(t, int64(0), c.GetNumBytesInCache())
+
+	c.AddEventData(key, start, "et=18")
+	c.AddActionData(key, start, "et=1&na=action")
+
+	assert.False(t, c.IsEmpty(key))
+	assert.Equal(t, size("et=18", "et=1&na=action"), c.GetNumBytesInCache())
+	assert.Equal(t, []caching.BeaconKey{key}, c.GetBeaconKeys())
+}
+
+func testKeysAreIndependent(t *testing.T, c caching.BeaconCache) {
+	c.AddEventData(key, start, "et=18&first")
+	c.AddEventData(otherKey, start, "et=18&second")
+
+	keys := c.GetBeaconKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].BeaconId < keys[j].BeaconId })
+	assert.Equal(t, []caching.BeaconKey{key, otherKey}, keys)
+
+	assert.Equal(t, "&et=18&first", sendAll(c, key))
+	c.DeleteCacheEntry(key)
+	assert.Equal(t, size("et=18&second"), c.GetNumBytesInCache())
+	assert.Equal(t, "&et=18&second", sendAll(c, otherKey))
+}
+
+func testChunkContainsEventsBeforeActions(t *testing.T, c caching.BeaconCache) {
+	c.AddActionData(key, start, "a1")
+	c.AddEventData(key, start.Add(time.Second), "e1")
+	c.AddEventData(key, start.Add(2*time.Second), "e2")
+
+	assert.False(t, c.HasDataForSending(key))
+	c.PrepareDataForSending(key)
+	assert.True(t, c.HasDataForSending(key))
+	assert.Equal(t, int64(0), c.GetNumBytesInCache(), "prepared records are not counted")
+
+	assert.Equal(t, "prefix&e1&e2&a1", c.GetNextBeaconChunk(key, "prefix", 1024, '&'))
+	c.RemoveChunkedData(key)
+	assert.False(t, c.HasDataForSending(key))
+	assert.Equal(t, "", c.GetNextBeaconChunk(key, "prefix", 1024, '&'))
+}
+
+func testChunkRespectsMaxSize(t *testing.T, c caching.BeaconCache) {
+	c.AddEventData(key, start, "first")
+	c.AddEventData(key, start, "second")
+	c.AddEventData(key, start, "third")
+
+	c.PrepareDataForSending(key)
+	// records are added while the chunk is not longer than maxSize
+	assert.Equal(t, "p&first&second", c.GetNextBeaconChunk(key, "p", 7, '&'))
+	c.RemoveChunkedData(key)
+
+	assert.True(t, c.HasDataForSending(key))
+	assert.Equal(t, "p&third", c.GetNextBeaconChunk(key, "p", 7, '&'))
+	c.RemoveChunkedData(key)
+	assert.False(t, c.HasDataForSending(key))
+}
+
+func testDataAddedAfterPrepareIsNotSent(t *testing.T, c caching.BeaconCache) {
+	c.AddEventData(key, start, "before")
+	c.PrepareDataForSending(key)
+	c.AddEventData(key, start, "after")
+
+	assert.Equal(t, size("after"), c.GetNumBytesInCache())
+	assert.Equal(t, "&before", c.GetNextBeaconChunk(key, "", 1024, '&'))
+	c.RemoveChunkedData(key)
+
+	assert.Equal(t, "&after", sendAll(c, key))
+}
+
+func testResetChunkedData(t *testing.T, c caching.BeaconCache) {
+	c.AddEventData(key, start, "first")
+	c.PrepareDataForSending(key)
+	c.AddEventData(key, start, "second")
+	c.GetNextBeaconChunk(key, "", 1024, '&')
+
+	c.ResetChunkedData(key)
+	assert.False(t, c.HasDataForSending(key))
+	assert.Equal(t, size("first", "second"), c.GetNumBytesInCache())
+	assert.Equal(t, "&first&second", sendAll(c, key))
+}
+
+func testDeleteCacheEntry(t *testing.T, c caching.BeaconCache) {
+	c.AddEventData(key, start, "event")
+	c.AddActionData(key, start, "action")
+
+	c.DeleteCacheEntry(key)
+	assert.True(t, c.IsEmpty(key))
+	assert.Empty(t, c.GetBeaconKeys())
+	assert.Equal(t, int64(0), c.GetNumBytesInCache())
+	assert.Equal(t, "", sendAll(c, key))
+}
+
+func testGetEvictableRecords(t *testing.T, c caching.BeaconCache) {
+	c.AddEventData(key, start, "prepared")
+	c.PrepareDataForSending(key)
+	assert.Empty(t, c.GetEvictableRecords(key), "prepared records are ignored")
+
+	c.AddEventData(key, start.Add(2*time.Second), "e2")
+	c.AddActionData(key, start.Add(time.Second), "a1")
+	c.AddEventData(key, start.Add(3*time.Second), "event3")
+	records := c.GetEvictableRecords(key)
+	if assert.Equal(t, 3, len(records)) {
+		assert.True(t, start.Add(time.Second).Equal(records[0].Timestamp))
+		assert.Equal(t, size("a1"), records[0].Size)
+		assert.True(t, start.Add(2*time.Second).Equal(records[1].Timestamp))
+		assert.Equal(t, size("event3"), records[2].Size)
+	}
+
+	// the records are evicted in the returned order
+	numRecords, bytes := c.EvictRecordsByNumber(key, 2)
+	assert.Equal(t, 2, numRecords)
+	assert.Equal(t, records[0].Size+records[1].Size, bytes)
+}
+
+func testEvictRecordsByAge(t *testing.T, c caching.BeaconCache) {
+	c.AddEventData(key, start, "e0")
+	c.AddActionData(key, start.Add(time.Second), "a1")
+	c.AddEventData(key, start.Add(2*time.Second), "e2")
+
+	records, bytes := c.EvictRecordsByAge(key, start.Add(time.Second))
+	assert.Equal(t, 2, records)
+	assert.Equal(t, size("e0", "a1"), bytes)
+	assert.Equal(t, size("e2"), c.GetNumBytesInCache())
+
+	records, bytes = c.EvictRecordsByAge(key, start.Add(time.Second))
+	assert.Equal(t, 0, records)
+	assert.Equal(t, int64(0), bytes)
+	assert.Equal(t, "&e2", sendAll(c, key))
+}
+
+func testEvictRecordsByNumber(t *testing.T, c caching.BeaconCache) {
+	c.AddEventData(key, start.Add(2*time.Second), "e2")
+	c.AddActionData(key, start, "a0")
+	c.AddEventData(key, start.Add(time.Second), "e1")
+	c.AddActionData(key, start.Add(3*time.Second), "a3")
+
+	records, bytes := c.EvictRecordsByNumber(key, 2)
+	assert.Equal(t, 2, records)
+	assert.Equal(t, size("a0", "e1"), bytes)
+	assert.Equal(t, size("e2", "a3"), c.GetNumBytesInCache())
+
+	records, _ = c.EvictRecordsByNumber(key, 5)
+	assert.Equal(t, 2, records, "only the records that exist are counted")
+	assert.Equal(t, int64(0), c.GetNumBytesInCache())
+	assert.True(t, c.IsEmpty(key))
+}
+
+func testRemoveRecordsIf(t *testing.T, c caching.BeaconCache) {
+	c.AddEventData(key, start, "keep")
+	c.AddEventData(key, start, "drop prepared")
+	c.PrepareDataForSending(key)
+	c.AddEventData(key, start, "drop")
+	c.AddActionData(key, start, "keep action")
+
+	removed := c.RemoveRecordsIf(key, func(data string) bool { return data[:4] == "drop" })
+	assert.Equal(t, 2, removed)
+	assert.Equal(t, size("keep action"), c.GetNumBytesInCache())
+	assert.Equal(t, "&keep", c.GetNextBeaconChunk(key, "", 1024, '&'))
+	c.RemoveChunkedData(key)
+	assert.Equal(t, "&keep action", sendAll(c, key))
+}
+
+func testMetadata(t *testing.T, cache caching.BeaconCache) {
+	c, ok := cache.(caching.MetadataStore)
+	if !ok {
+		t.Skip("the cache is not a caching.MetadataStore")
+	}
+	assert.Equal(t, "", c.GetMetadata(key))
+
+	c.SetMetadata(key, "first")
+	c.SetMetadata(key, "second")
+	cache.AddEventData(key, start, "event")
+	assert.Equal(t, "second", c.GetMetadata(key))
+	assert.Equal(t, "", c.GetMetadata(otherKey))
+
+	cache.DeleteCacheEntry(key)
+	assert.Equal(t, "", c.GetMetadata(key))
+}
+
+func testUnknownKeys(t *testing.T, c caching.BeaconCache) {
+	c.PrepareDataForSending(key)
+	c.RemoveChunkedData(key)
+	c.ResetChunkedData(key)
+	c.DeleteCacheEntry(key)
+
+	assert.False(t, c.HasDataForSending(key))
+	assert.Equal(t, "", c.GetNextBeaconChunk(key, "prefix", 1024, '&'))
+	assert.Empty(t, c.GetEvictableRecords(key))
+	records, bytes := c.EvictRecordsByAge(key, start)
+	assert.Equal(t, 0, records)
+	assert.Equal(t, int64(0), bytes)
+	records, bytes = c.EvictRecordsByNumber(key, 1)
+	assert.Equal(t, 0, records)
+	assert.Equal(t, int64(0), bytes)
+	assert.Equal(t, 0, c.RemoveRecordsIf(key, func(string) bool { return true }))
+	assert.True(t, c.IsEmpty(key))
+}
+
+func testConcurrentAdds(t *testing.T, c caching.BeaconCache) {
+	const goroutines = 8
+	const records = 100
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			k := caching.NewBeaconKey(int32(g%2), 0)
+			for i := 0; i < records; i++ {
+				c.AddEventData(k, start, "0123456789")
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	assert.Equal(t, goroutines*records*size("0123456789"), c.GetNumBytesInCache())
+}
@@ -1,15 +1,15 @@
 package caching
 
 import (
-	log "github.com/sirupsen/logrus"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"os"
 	"testing"
 )
 
-var logger *log.Logger
+var logger log.Logger
 
 func TestMain(m *testing.M) {
-	logger = log.New()
-	logger.SetLevel(log.DebugLevel)
+	logger = log.NewLogrusLogger(nil)
+	logger.(log.LevelSetter).SetLevel(log.DebugLevel)
 	os.Exit(m.Run())
 }
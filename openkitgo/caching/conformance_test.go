@@ -0,0 +1,34 @@
+package caching_test
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching/cachetest"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers/providerstest"
+	"testing"
+	"time"
+)
+
+func TestBeaconCacheConformance(t *testing.T) {
+	cachetest.Run(t, func(t *testing.T) caching.BeaconCache {
+		return caching.NewBeaconCache(log.NewNopLogger())
+	})
+}
+
+func TestPersistentBeaconCacheConformance(t *testing.T) {
+	cachetest.Run(t, func(t *testing.T) caching.BeaconCache {
+		config := configuration.NewBeaconCacheConfiguration(100*365*24*time.Hour, 1<<20, 1<<21)
+		c, err := caching.NewPersistentBeaconCache(log.NewNopLogger(), t.TempDir(), config, providerstest.NewFakeClock(time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	})
+}
+
+func TestNotifyingBeaconCacheConformance(t *testing.T) {
+	cachetest.Run(t, func(t *testing.T) caching.BeaconCache {
+		return caching.NewNotifyingBeaconCache(caching.NewBeaconCache(log.NewNopLogger()), &caching.Notifier{})
+	})
+}
@@ -16,6 +16,9 @@ type BeaconCacheEntry struct {
 	actionDataBeingSent []*BeaconCacheRecord
 
 	totalNumBytes int64
+
+	// metadata is stored with the records by persistent caches, see MetadataStore
+	metadata string
 }
 
 func (e *BeaconCacheEntry) addEventData(record *BeaconCacheRecord) {
@@ -93,7 +96,7 @@ func (e *BeaconCacheEntry) removeDataMarkedForSending() {
 	var keepActions []*BeaconCacheRecord
 	for _, eventRecord := range e.actionDataBeingSent {
 		if !eventRecord.markedForSending {
-			keepActions = append(keepEvents, eventRecord)
+			keepActions = append(keepActions, eventRecord)
 		}
 	}
 	e.actionDataBeingSent = keepActions
@@ -123,14 +126,17 @@ func (e *BeaconCacheEntry) resetDataMarkedForSending() {
 
 	e.eventData = e.eventDataBeingSent
 	e.actionData = e.actionDataBeingSent
+	e.eventDataBeingSent = nil
+	e.actionDataBeingSent = nil
 
 	e.totalNumBytes += numBytes
 
 }
 
-func (e *BeaconCacheEntry) removeRecordsOlderThan(timestamp time.Time) int {
+func (e *BeaconCacheEntry) removeRecordsOlderThan(timestamp time.Time) (int, int64) {
 
 	numRecordsRemoved := 0
+	numBytesRemoved := int64(0)
 
 	var keepEvents []*BeaconCacheRecord
 	for _, eventRecord := range e.eventData {
@@ -138,6 +144,7 @@ func (e *BeaconCacheEntry) removeRecordsOlderThan(timestamp time.Time) int {
 			keepEvents = append(keepEvents, eventRecord)
 		} else {
 			numRecordsRemoved += 1
+			numBytesRemoved += eventRecord.getDataSizeInBytes()
 		}
 	}
 	e.eventData = keepEvents
@@ -148,48 +155,112 @@ func (e *BeaconCacheEntry) removeRecordsOlderThan(timestamp time.Time) int {
 			keepActions = append(keepActions, actionRecord)
 		} else {
 			numRecordsRemoved += 1
+			numBytesRemoved += actionRecord.getDataSizeInBytes()
 		}
 	}
 	e.actionData = keepActions
 
-	return numRecordsRemoved
+	e.totalNumBytes -= numBytesRemoved
+	return numRecordsRemoved, numBytesRemoved
+
+}
+
+// removeRecordsIf removes all records, including those prepared for sending, for which remove returns true.
+// The returned size only counts the records that were not prepared for sending, like totalNumBytes.
+func (e *BeaconCacheEntry) removeRecordsIf(remove func(data string) bool) (int, int64) {
+	numRecordsRemoved := 0
+	numBytesRemoved := int64(0)
+
+	filter := func(records []*BeaconCacheRecord, prepared bool) []*BeaconCacheRecord {
+		var keep []*BeaconCacheRecord
+		for _, record := range records {
+			if remove(record.data) {
+				numRecordsRemoved += 1
+				if !prepared {
+					numBytesRemoved += record.getDataSizeInBytes()
+				}
+			} else {
+				keep = append(keep, record)
+			}
+		}
+		return keep
+	}
+	e.eventData = filter(e.eventData, false)
+	e.actionData = filter(e.actionData, false)
+	e.eventDataBeingSent = filter(e.eventDataBeingSent, true)
+	e.actionDataBeingSent = filter(e.actionDataBeingSent, true)
+
+	e.totalNumBytes -= numBytesRemoved
+	return numRecordsRemoved, numBytesRemoved
+}
 
+// evictableRecords returns the records that are not prepared for sending in the order removeOldestRecords
+// removes them
+func (e *BeaconCacheEntry) evictableRecords() []RecordInfo {
+	sortByTimestamp(e.eventData)
+	sortByTimestamp(e.actionData)
+
+	records := make([]RecordInfo, 0, len(e.eventData)+len(e.actionData))
+	events, actions := e.eventData, e.actionData
+	for len(events) > 0 || len(actions) > 0 {
+		var record *BeaconCacheRecord
+		if len(actions) == 0 || (len(events) > 0 && events[0].timestamp.Before(actions[0].timestamp)) {
+			record, events = events[0], events[1:]
+		} else {
+			record, actions = actions[0], actions[1:]
+		}
+		records = append(records, RecordInfo{Timestamp: record.timestamp, Size: record.getDataSizeInBytes()})
+	}
+	return records
 }
 
-func (e *BeaconCacheEntry) removeOldestRecords(numRecords int) int {
+func (e *BeaconCacheEntry) removeOldestRecords(numRecords int) (int, int64) {
 
 	numRecordsRemoved := 0
+	numBytesRemoved := int64(0)
 
 	// First, sort our slices, oldest events and actions first
-	sort.Slice(e.eventData, func(i, j int) bool {
-		return e.eventData[i].timestamp.Before(e.eventData[j].timestamp)
-	})
-
-	sort.Slice(e.actionData, func(i, j int) bool {
-		return e.actionData[i].timestamp.Before(e.actionData[j].timestamp)
-	})
+	sortByTimestamp(e.eventData)
+	sortByTimestamp(e.actionData)
 
 	for numRecordsRemoved < numRecords {
 
 		// if we have actions and events, remove the oldest one
 		if len(e.actionData) > 0 && len(e.eventData) > 0 {
 			if e.eventData[0].timestamp.Before(e.actionData[0].timestamp) {
+				numBytesRemoved += e.eventData[0].getDataSizeInBytes()
 				e.eventData = e.eventData[1:]
 			} else {
+				numBytesRemoved += e.actionData[0].getDataSizeInBytes()
 				e.actionData = e.actionData[1:]
 			}
 		} else if len(e.actionData) > 0 {
 			// We only have actions, remove one
+			numBytesRemoved += e.actionData[0].getDataSizeInBytes()
 			e.actionData = e.actionData[1:]
 		} else if len(e.eventData) > 0 {
 			// We only have events, remove one
+			numBytesRemoved += e.eventData[0].getDataSizeInBytes()
 			e.eventData = e.eventData[1:]
+		} else {
+			// Nothing left to remove
+			break
 		}
-		// This always increases, even if both are empty so we are guaranteed to leave
 		numRecordsRemoved += 1
 
 	}
 
-	return numRecordsRemoved
+	e.totalNumBytes -= numBytesRemoved
+	return numRecordsRemoved, numBytesRemoved
+
+}
 
+// sortByTimestamp sorts records oldest first, records are mostly added in order so the check is usually enough
+func sortByTimestamp(records []*BeaconCacheRecord) {
+	less := func(i, j int) bool {
+		return records[i].timestamp.Before(records[j].timestamp)
+	}
+	if !sort.SliceIsSorted(records, less) {
+		sort.SliceStable(records, less)
+	}
 }
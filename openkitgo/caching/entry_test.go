@@ -29,3 +29,46 @@ func TestEntryDataManipulation(t *testing.T) {
 	assert.Equal(t, 2, len(e.eventData))
 
 }
+
+func TestEntryRemoveRecordsIfKeepsSizeOfPreparedRecords(t *testing.T) {
+	e := BeaconCacheEntry{}
+	e.addEventData(NewBeaconCacheRecord(time.Now(), "prepared"))
+	e.copyDataForSending()
+	assert.Equal(t, int64(0), e.totalNumBytes)
+
+	e.addEventData(NewBeaconCacheRecord(time.Now(), "new"))
+
+	records, bytes := e.removeRecordsIf(func(string) bool { return true })
+	assert.Equal(t, 2, records)
+	assert.Equal(t, int64(6), bytes, "prepared records are not part of totalNumBytes")
+	assert.Equal(t, int64(0), e.totalNumBytes)
+}
+
+func TestEntryEvictionLowersSize(t *testing.T) {
+	now := time.Now()
+	e := BeaconCacheEntry{}
+	e.addEventData(NewBeaconCacheRecord(now.Add(-time.Hour), "old"))
+	e.addActionData(NewBeaconCacheRecord(now, "new"))
+	e.addEventData(NewBeaconCacheRecord(now, "newest"))
+
+	records, bytes := e.removeRecordsOlderThan(now.Add(-time.Minute))
+	assert.Equal(t, 1, records)
+	assert.Equal(t, int64(6), bytes)
+	assert.Equal(t, int64(18), e.totalNumBytes)
+
+	records, bytes = e.removeOldestRecords(1)
+	assert.Equal(t, 1, records)
+	assert.Equal(t, int64(6), bytes)
+	assert.Equal(t, int64(12), e.totalNumBytes)
+}
+
+func TestEntryRemoveOldestRecordsCountsOnlyRemovedRecords(t *testing.T) {
+	e := BeaconCacheEntry{}
+	e.addEventData(NewBeaconCacheRecord(time.Now(), "event"))
+	e.addActionData(NewBeaconCacheRecord(time.Now(), "action"))
+
+	records, bytes := e.removeOldestRecords(5)
+	assert.Equal(t, 2, records)
+	assert.Equal(t, int64(22), bytes)
+	assert.Equal(t, int64(0), e.totalNumBytes)
+}
@@ -2,7 +2,9 @@ package caching
 
 import (
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
-	log "github.com/sirupsen/logrus"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"sync"
 	"time"
 )
 
@@ -11,34 +13,53 @@ const (
 	EVICTION_THREAD_JOIN_TIMEOUT = 2 * time.Second
 )
 
+// BeaconCacheEvictionStrategy removes records from a cache. scheduled is true when the strategy runs because
+// the eviction interval elapsed, and false when it runs because data was added.
 type BeaconCacheEvictionStrategy interface {
-	execute()
+	execute(scheduled bool)
 }
 
 type BeaconCacheEvictor struct {
-	log     *log.Logger
-	channel *chan bool
-	alive   bool
-	cache   *BeaconCache
-	config  *configuration.BeaconCacheConfiguration
+	log      log.Logger
+	mutex    sync.Mutex
+	stop     chan bool
+	done     chan struct{}
+	alive    bool
+	cache    BeaconCache
+	notifier *Notifier
+	config   *configuration.BeaconCacheConfiguration
+	clock    providers.Clock
 }
 
-func EvictionRoutine(log *log.Logger, cache *BeaconCache, strategies ...BeaconCacheEvictionStrategy) *chan bool {
+// EvictionRoutine runs the strategies whenever notifier reports added data and every interval, until stop is closed
+func EvictionRoutine(log log.Logger, notifier *Notifier, clock providers.Clock, interval time.Duration, stop chan bool, done chan struct{}, strategies ...BeaconCacheEvictionStrategy) {
 
-	recordAdded := make(chan bool)
-	stop := make(chan bool)
+	recordAdded := NewObserverChannel()
 
-	cache.AddObservable(&recordAdded)
+	notifier.AddObservable(&recordAdded)
+
+	if interval <= 0 {
+		interval = configuration.DEFAULT_EVICTION_INTERVAL
+	}
+	timer := clock.NewTimer(interval)
 
 	go func() {
 		log.Debug("EvictionRoutine.run()")
+		defer close(done)
+		defer notifier.RemoveObservable(&recordAdded)
+		defer timer.Stop()
 		for {
 
 			select {
 			case <-recordAdded:
 				for _, strategy := range strategies {
-					strategy.execute()
+					strategy.execute(false)
 				}
+			case <-timer.C():
+				for _, strategy := range strategies {
+					strategy.execute(true)
+				}
+				timer.Reset(interval)
 			case <-stop:
 				log.Debug("EvictionRoutine.stop()")
 				return
@@ -46,36 +67,57 @@ func EvictionRoutine(log *log.Logger, cache *BeaconCache, strategies ...BeaconCa
 		}
 
 	}()
-
-	return &stop
-
 }
 
+// NewBeaconCacheEvictor returns an evictor for cache that wakes up when notifier reports added data,
+// see NewNotifyingBeaconCache
 func NewBeaconCacheEvictor(
-	log *log.Logger,
-	cache *BeaconCache,
+	log log.Logger,
+	cache BeaconCache,
+	notifier *Notifier,
 	configuration *configuration.BeaconCacheConfiguration,
+	clock providers.Clock,
 ) *BeaconCacheEvictor {
 
 	return &BeaconCacheEvictor{
-		log:    log,
-		cache:  cache,
-		config: configuration,
+		log:      log,
+		clock:    clock,
+		done:     make(chan struct{}),
+		cache:    cache,
+		notifier: notifier,
+		config:   configuration,
 	}
 }
 
+// Stop ends the eviction goroutine, it is safe to call Stop more than once and from several goroutines
 func (e *BeaconCacheEvictor) Stop() {
-	*e.channel <- true
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.alive {
+		close(e.stop)
+		e.alive = false
+	}
+}
+
+// Done is closed once the eviction goroutine has stopped
+func (e *BeaconCacheEvictor) Done() <-chan struct{} {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.done
 }
 
 func (e *BeaconCacheEvictor) Start() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
 
 	if !e.alive {
 		spaceEvictionStrategy := NewSpaceEvictionStrategy(e.log, e.cache, e.config)
-		timeEvictionStrategy := NewTimeEvictionStrategy(e.log, e.cache, e.config)
-		e.channel = EvictionRoutine(e.log, e.cache, spaceEvictionStrategy, timeEvictionStrategy)
+		timeEvictionStrategy := NewTimeEvictionStrategy(e.log, e.cache, e.config, e.clock)
+		e.stop = make(chan bool)
+		e.done = make(chan struct{})
+		EvictionRoutine(e.log, e.notifier, e.clock, e.config.EvictionInterval, e.stop, e.done, spaceEvictionStrategy, timeEvictionStrategy)
 		e.alive = true
 	} else {
-		log.Debug("Not starting the evictor because it is already running")
+		e.log.Debug("Not starting the evictor because it is already running")
 	}
 }
@@ -0,0 +1,93 @@
+package caching
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers/providerstest"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEvictorEvictsOldRecordsWithoutNewData(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := providerstest.NewFakeClock(start)
+
+	c := newBeaconCache(logger)
+	k := NewBeaconKey(1, 1)
+	c.AddEventData(k, start.Add(-50*time.Minute), "old")
+
+	config := configuration.NewBeaconCacheConfiguration(time.Hour, 0, 1000)
+	e := NewBeaconCacheEvictor(logger, c, &Notifier{}, config, clock)
+	e.Start()
+	defer e.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(11 * time.Minute)
+
+	deadline := time.Now().Add(time.Second)
+	for !c.IsEmpty(k) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, c.IsEmpty(k))
+}
+
+func TestEvictorEvictsOnTheTickAfterAddedData(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := providerstest.NewFakeClock(start)
+
+	var n Notifier
+	inner := newBeaconCache(logger)
+	c := NewNotifyingBeaconCache(inner, &n)
+	config := configuration.NewBeaconCacheConfiguration(time.Hour, 0, 1000)
+	config.EvictionInterval = 10 * time.Minute
+	e := NewBeaconCacheEvictor(logger, c, &n, config, clock)
+	e.Start()
+	defer e.Stop()
+	clock.BlockUntil(1)
+
+	waitUntilEmpty := func(k BeaconKey) bool {
+		deadline := time.Now().Add(time.Second)
+		for !c.IsEmpty(k) && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		return c.IsEmpty(k)
+	}
+
+	// added data runs the time eviction halfway through the interval, the record of aging is not expired yet
+	aging := NewBeaconKey(1, 0)
+	inner.AddEventData(aging, start.Add(-52*time.Minute), "aging")
+	clock.Advance(5 * time.Minute)
+	expired := NewBeaconKey(2, 0)
+	c.AddEventData(expired, start.Add(-2*time.Hour), "expired")
+	assert.True(t, waitUntilEmpty(expired))
+	assert.False(t, c.IsEmpty(aging))
+
+	// the next tick evicts it although the last run was less than an interval ago
+	clock.Advance(5 * time.Minute)
+	assert.True(t, waitUntilEmpty(aging))
+}
+
+func TestEvictorStopIsIdempotent(t *testing.T) {
+	c := newBeaconCache(logger)
+	config := configuration.NewBeaconCacheConfiguration(time.Hour, 0, 1000)
+	e := NewBeaconCacheEvictor(logger, c, &Notifier{}, config, providerstest.NewFakeClock(time.Now()))
+	e.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.Stop()
+		}()
+	}
+	wg.Wait()
+	e.Stop()
+
+	select {
+	case <-e.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the eviction goroutine did not stop")
+	}
+}
@@ -0,0 +1,49 @@
+package caching
+
+import (
+	"sync"
+)
+
+// Notifier wakes up observers of a BeaconCache without blocking the goroutine that adds data.
+// Notifications are sent without waiting, so an observer that uses a channel with a buffer of one
+// gets a single wakeup for all data added while it was busy, and nothing blocks once it stopped reading.
+// The zero value is ready to use.
+type Notifier struct {
+	mutex     sync.RWMutex
+	observers []*chan bool
+}
+
+// NewObserverChannel returns a channel with the buffer of one that coalesces notifications
+func NewObserverChannel() chan bool {
+	return make(chan bool, 1)
+}
+
+func (n *Notifier) AddObservable(channel *chan bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.observers = append(n.observers, channel)
+}
+
+// RemoveObservable stops notifying channel, it is a no-op if channel is not registered
+func (n *Notifier) RemoveObservable(channel *chan bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	for i, o := range n.observers {
+		if o == channel {
+			n.observers = append(n.observers[:i], n.observers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Notify wakes up every observer that has no notification pending
+func (n *Notifier) Notify() {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	for _, o := range n.observers {
+		select {
+		case *o <- true:
+		default:
+		}
+	}
+}
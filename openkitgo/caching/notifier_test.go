@@ -0,0 +1,190 @@
+package caching
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNotifierCoalescesNotifications(t *testing.T) {
+	var n Notifier
+	observer := NewObserverChannel()
+	n.AddObservable(&observer)
+
+	for i := 0; i < 10; i++ {
+		n.Notify()
+	}
+
+	assert.Equal(t, 1, len(observer))
+	<-observer
+	n.Notify()
+	assert.Equal(t, 1, len(observer))
+}
+
+func TestNotifierDoesNotBlockWithoutReader(t *testing.T) {
+	var n Notifier
+	unbuffered := make(chan bool)
+	n.AddObservable(&unbuffered)
+
+	done := make(chan struct{})
+	go func() {
+		n.Notify()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Notify blocked")
+	}
+}
+
+func TestEvictorRemovesObserverOnStop(t *testing.T) {
+	var n Notifier
+	config := configuration.NewBeaconCacheConfiguration(time.Hour, 0, 1000)
+	e := NewBeaconCacheEvictor(logger, newBeaconCache(logger), &n, config, providers.NewDefaultClock())
+
+	for i := 0; i < 3; i++ {
+		e.Start()
+		e.Stop()
+		<-e.Done()
+	}
+
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	assert.Empty(t, n.observers)
+}
+
+func TestNotifierRemoveObservable(t *testing.T) {
+	var n Notifier
+	first := NewObserverChannel()
+	second := NewObserverChannel()
+	n.AddObservable(&first)
+	n.AddObservable(&second)
+
+	n.RemoveObservable(&first)
+	n.RemoveObservable(&first)
+	n.Notify()
+
+	assert.Equal(t, 0, len(first))
+	assert.Equal(t, 1, len(second))
+}
+
+func TestNotifyingBeaconCache(t *testing.T) {
+	var n Notifier
+	observer := make(chan bool, 10)
+	n.AddObservable(&observer)
+	c := NewNotifyingBeaconCache(newBeaconCache(logger), &n)
+	k := NewBeaconKey(1, 0)
+
+	c.AddEventData(k, time.Now(), "event")
+	c.AddActionData(k, time.Now(), "action")
+	c.PrepareDataForSending(k)
+	c.ResetChunkedData(k)
+	assert.Equal(t, 3, len(observer))
+
+	// the optional interfaces of the wrapped cache are passed on
+	SetMetadata(c, k, "metadata")
+	assert.Equal(t, "metadata", GetMetadata(c, k))
+	assert.NoError(t, c.(Flusher).Flush())
+}
+
+func TestAddDataAfterEvictorStopped(t *testing.T) {
+	var n Notifier
+	c := NewNotifyingBeaconCache(newBeaconCache(logger), &n)
+	config := configuration.NewBeaconCacheConfiguration(time.Hour, 0, 1000)
+	e := NewBeaconCacheEvictor(logger, c, &n, config, providers.NewDefaultClock())
+	e.Start()
+	e.Stop()
+	<-e.Done()
+
+	done := make(chan struct{})
+	go func() {
+		k := NewBeaconKey(1, 0)
+		for i := 0; i < 3; i++ {
+			c.AddEventData(k, time.Now(), "event")
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("AddEventData blocked after the evictor stopped")
+	}
+}
+
+// blockingNotifyCache wakes up its observer with a blocking send after every added event, the way the evictor was
+// notified before Notifier, it is the baseline of BenchmarkAddEventData
+type blockingNotifyCache struct {
+	BeaconCache
+	recordAdded chan bool
+}
+
+func (c *blockingNotifyCache) AddEventData(key BeaconKey, timestamp time.Time, data string) {
+	c.BeaconCache.AddEventData(key, timestamp, data)
+	c.recordAdded <- true
+}
+
+// BenchmarkAddEventData measures the reporting hot path while the evictor is running, the blocking case shows
+// the cost of waiting for the evictor on every added event
+func BenchmarkAddEventData(b *testing.B) {
+	nop := log.NewNopLogger()
+	config := configuration.NewBeaconCacheConfiguration(time.Hour, 80<<20, 100<<20)
+
+	run := func(b *testing.B, c BeaconCache) {
+		keys := make([]BeaconKey, 1000)
+		for i := range keys {
+			keys[i] = NewBeaconKey(int32(i), 0)
+			c.AddEventData(keys[i], time.Now(), "et=18")
+		}
+
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				c.AddEventData(keys[i%len(keys)], time.Now(), "et=10&na=event")
+				i++
+			}
+		})
+	}
+
+	b.Run("observer=none", func(b *testing.B) {
+		run(b, newBeaconCache(nop))
+	})
+
+	b.Run("observer=coalescing", func(b *testing.B) {
+		var n Notifier
+		c := NewNotifyingBeaconCache(newBeaconCache(nop), &n)
+		e := NewBeaconCacheEvictor(nop, c, &n, config, providers.NewDefaultClock())
+		e.Start()
+		defer e.Stop()
+		run(b, c)
+	})
+
+	b.Run("observer=blocking", func(b *testing.B) {
+		c := &blockingNotifyCache{BeaconCache: newBeaconCache(nop), recordAdded: make(chan bool)}
+		strategies := []BeaconCacheEvictionStrategy{
+			NewSpaceEvictionStrategy(nop, c, config),
+			NewTimeEvictionStrategy(nop, c, config, providers.NewDefaultClock()),
+		}
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			for {
+				select {
+				case <-c.recordAdded:
+					for _, strategy := range strategies {
+						strategy.execute(false)
+					}
+				case <-stop:
+					return
+				}
+			}
+		}()
+		run(b, c)
+	})
+}
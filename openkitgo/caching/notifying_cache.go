@@ -0,0 +1,47 @@
+package caching
+
+import (
+	"time"
+)
+
+// notifyingBeaconCache notifies a Notifier whenever data was added to the cache it wraps
+type notifyingBeaconCache struct {
+	BeaconCache
+	notifier *Notifier
+}
+
+// NewNotifyingBeaconCache wraps cache so that notifier is notified whenever data is added to it, the
+// BeaconCacheEvictor observes notifier. MetadataStore and Flusher calls are passed on to cache.
+func NewNotifyingBeaconCache(cache BeaconCache, notifier *Notifier) BeaconCache {
+	return &notifyingBeaconCache{BeaconCache: cache, notifier: notifier}
+}
+
+func (c *notifyingBeaconCache) AddEventData(key BeaconKey, timestamp time.Time, data string) {
+	c.BeaconCache.AddEventData(key, timestamp, data)
+	c.notifier.Notify()
+}
+
+func (c *notifyingBeaconCache) AddActionData(key BeaconKey, timestamp time.Time, data string) {
+	c.BeaconCache.AddActionData(key, timestamp, data)
+	c.notifier.Notify()
+}
+
+func (c *notifyingBeaconCache) ResetChunkedData(key BeaconKey) {
+	c.BeaconCache.ResetChunkedData(key)
+	c.notifier.Notify()
+}
+
+func (c *notifyingBeaconCache) SetMetadata(key BeaconKey, data string) {
+	SetMetadata(c.BeaconCache, key, data)
+}
+
+func (c *notifyingBeaconCache) GetMetadata(key BeaconKey) string {
+	return GetMetadata(c.BeaconCache, key)
+}
+
+func (c *notifyingBeaconCache) Flush() error {
+	if flusher, ok := c.BeaconCache.(Flusher); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
@@ -12,6 +12,12 @@ type BeaconCacheRecord struct {
 	markedForSending bool
 }
 
+// RecordInfo is the timestamp and size in bytes of a cached record
+type RecordInfo struct {
+	Timestamp time.Time
+	Size      int64
+}
+
 func NewBeaconCacheRecord(timestamp time.Time, data string) *BeaconCacheRecord {
 	return &BeaconCacheRecord{
 		timestamp: timestamp,
@@ -0,0 +1,305 @@
+package caching
+
+import (
+	"bufio"
+	"container/list"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SEGMENT_FILE_EXTENSION = ".log"
+	// MAX_OPEN_SEGMENT_FILES limits the file handles of a segmentStore, the least recently written file is closed first
+	MAX_OPEN_SEGMENT_FILES = 64
+
+	segmentKindEvent    = 'e'
+	segmentKindAction   = 'a'
+	segmentKindMetadata = 'm'
+)
+
+// segmentStore keeps an append-only segment file per BeaconKey in dir.
+// New records are written to the open file of the key right away, so they survive a crash of the process.
+// sync and flush fsync the files, records written since can be lost if the machine crashes.
+// Removing records rewrites the file of the key with the records that are left.
+// Callers serialize the writes of a key with the mutex of its BeaconCacheEntry.
+type segmentStore struct {
+	dir string
+
+	mutex sync.Mutex
+	files map[BeaconKey]*list.Element
+	lru   *list.List // of *segmentFile, most recently written at the front
+}
+
+type segmentFile struct {
+	key    BeaconKey
+	file   *os.File
+	writer *bufio.Writer
+}
+
+func newSegmentStore(dir string) (*segmentStore, error) {
+	if err := checkSegmentDirectory(dir); err != nil {
+		return nil, err
+	}
+	return &segmentStore{dir: dir, files: map[BeaconKey]*list.Element{}, lru: list.New()}, nil
+}
+
+// checkSegmentDirectory creates dir if needed and checks that files can be written to it
+func checkSegmentDirectory(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, "check")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func (s *segmentStore) fileName(key BeaconKey) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d_%d%s", key.BeaconId, key.BeaconSeqNo, SEGMENT_FILE_EXTENSION))
+}
+
+func writeSegmentLine(w *bufio.Writer, kind byte, timestamp time.Time, data string) {
+	w.WriteByte(kind)
+	w.WriteByte(' ')
+	w.WriteString(strconv.FormatInt(timestamp.UnixNano(), 10))
+	w.WriteByte(' ')
+	w.WriteString(strconv.Quote(data))
+	w.WriteByte('\n')
+}
+
+// open returns the open segment file of key, opening it and closing the least recently written file if needed.
+// Callers hold s.mutex.
+func (s *segmentStore) open(key BeaconKey) (*segmentFile, error) {
+	if element, ok := s.files[key]; ok {
+		s.lru.MoveToFront(element)
+		return element.Value.(*segmentFile), nil
+	}
+
+	for s.lru.Len() >= MAX_OPEN_SEGMENT_FILES {
+		if err := s.close(s.lru.Back().Value.(*segmentFile).key, true); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.OpenFile(s.fileName(key), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	segment := &segmentFile{key: key, file: f, writer: bufio.NewWriter(f)}
+	s.files[key] = s.lru.PushFront(segment)
+	return segment, nil
+}
+
+// close closes the segment file of key if it is open, flush writes the buffered records first.
+// Callers hold s.mutex.
+func (s *segmentStore) close(key BeaconKey, flush bool) error {
+	element, ok := s.files[key]
+	if !ok {
+		return nil
+	}
+	segment := s.lru.Remove(element).(*segmentFile)
+	delete(s.files, key)
+
+	var err error
+	if flush {
+		err = segment.writer.Flush()
+	}
+	if closeErr := segment.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", segment.file.Name(), err)
+	}
+	return nil
+}
+
+// append writes a record to the file of key, it is on disk after the next sync or flush
+func (s *segmentStore) append(key BeaconKey, kind byte, timestamp time.Time, data string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	segment, err := s.open(key)
+	if err != nil {
+		return err
+	}
+	writeSegmentLine(segment.writer, kind, timestamp, data)
+	return segment.writer.Flush()
+}
+
+// sync fsyncs the file of key
+func (s *segmentStore) sync(key BeaconKey) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.files[key]
+	if !ok {
+		return nil
+	}
+	segment := element.Value.(*segmentFile)
+	if err := segment.writer.Flush(); err != nil {
+		return err
+	}
+	return segment.file.Sync()
+}
+
+// flush fsyncs and closes the files of all keys, they are opened again by append
+func (s *segmentStore) flush() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var firstErr error
+	for s.lru.Len() > 0 {
+		segment := s.lru.Back().Value.(*segmentFile)
+		err := segment.writer.Flush()
+		if err == nil {
+			err = segment.file.Sync()
+		}
+		if closeErr := s.close(segment.key, false); err == nil {
+			err = closeErr
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// rewrite replaces the segment file of key with the metadata and records of entry
+func (s *segmentStore) rewrite(key BeaconKey, entry *BeaconCacheEntry) error {
+	tmp, err := ioutil.TempFile(s.dir, "rewrite-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	if entry.metadata != "" {
+		writeSegmentLine(w, segmentKindMetadata, time.Unix(0, 0), entry.metadata)
+	}
+	for _, records := range [][]*BeaconCacheRecord{entry.eventDataBeingSent, entry.eventData} {
+		for _, record := range records {
+			writeSegmentLine(w, segmentKindEvent, record.timestamp, record.data)
+		}
+	}
+	for _, records := range [][]*BeaconCacheRecord{entry.actionDataBeingSent, entry.actionData} {
+		for _, record := range records {
+			writeSegmentLine(w, segmentKindAction, record.timestamp, record.data)
+		}
+	}
+	// the new file is synced before it replaces the old one, so a crash leaves one of them complete
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.close(key, false)
+	return os.Rename(tmp.Name(), s.fileName(key))
+}
+
+func (s *segmentStore) remove(key BeaconKey) error {
+	s.mutex.Lock()
+	s.close(key, false)
+	s.mutex.Unlock()
+
+	if err := os.Remove(s.fileName(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// load reads all segment files in the directory and returns an error for every file that could not be read completely
+func (s *segmentStore) load() (map[BeaconKey]*BeaconCacheEntry, []error) {
+	entries := map[BeaconKey]*BeaconCacheEntry{}
+	var errs []error
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*"+SEGMENT_FILE_EXTENSION))
+	if err != nil {
+		return entries, []error{err}
+	}
+	for _, file := range files {
+		key, err := parseSegmentFileName(filepath.Base(file))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// the records before a malformed line, e.g. one that was cut off by a crash, are kept
+		entry, err := loadSegment(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", file, err))
+		}
+		if entry != nil {
+			entries[key] = entry
+		}
+	}
+	return entries, errs
+}
+
+func parseSegmentFileName(name string) (BeaconKey, error) {
+	parts := strings.Split(strings.TrimSuffix(name, SEGMENT_FILE_EXTENSION), "_")
+	if len(parts) != 2 {
+		return BeaconKey{}, fmt.Errorf("%s is not a segment file", name)
+	}
+	beaconID, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return BeaconKey{}, fmt.Errorf("%s is not a segment file", name)
+	}
+	beaconSeqNo, err := strconv.ParseInt(parts[1], 10, 32)
+	if err != nil {
+		return BeaconKey{}, fmt.Errorf("%s is not a segment file", name)
+	}
+	return NewBeaconKey(int32(beaconID), int32(beaconSeqNo)), nil
+}
+
+func loadSegment(file string) (*BeaconCacheEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entry := &BeaconCacheEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.SplitN(scanner.Text(), " ", 3)
+		if len(fields) != 3 || len(fields[0]) != 1 {
+			return entry, fmt.Errorf("line %d is malformed", line)
+		}
+		nanos, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return entry, fmt.Errorf("line %d: %v", line, err)
+		}
+		data, err := strconv.Unquote(fields[2])
+		if err != nil {
+			return entry, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		record := NewBeaconCacheRecord(time.Unix(0, nanos), data)
+		switch fields[0][0] {
+		case segmentKindEvent:
+			entry.addEventData(record)
+		case segmentKindAction:
+			entry.addActionData(record)
+		case segmentKindMetadata:
+			entry.metadata = data
+		default:
+			return entry, fmt.Errorf("line %d has unknown kind %q", line, fields[0])
+		}
+	}
+	return entry, scanner.Err()
+}
@@ -0,0 +1,124 @@
+package caching
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers/providerstest"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestPersistentCache(t *testing.T, dir string, now time.Time, upperMemoryBoundary int64) *beaconCache {
+	config := configuration.NewBeaconCacheConfiguration(time.Hour, upperMemoryBoundary/2, upperMemoryBoundary)
+	c, err := newPersistentBeaconCache(logger, dir, config, providerstest.NewFakeClock(now))
+	assert.NoError(t, err)
+	return c
+}
+
+func TestPersistentCacheReloadsRecords(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	k := NewBeaconKey(7, 2)
+
+	c := newTestPersistentCache(t, dir, now, 1000)
+	c.SetMetadata(k, "ip=10.0.0.1")
+	c.AddEventData(k, now, "et=18")
+	c.AddEventData(k, now, "et=10&na=sent")
+
+	// the prepared records are sent in several chunks, the record added meanwhile stays
+	c.PrepareDataForSending(k)
+	c.AddEventData(k, now, "et=60&na=later")
+	for c.GetNextBeaconChunk(k, "", 5, '&') != "" {
+		c.RemoveChunkedData(k)
+	}
+	c.AddActionData(k, now, "et=1&na=action with\nnewline")
+
+	reloaded := newTestPersistentCache(t, dir, now, 1000)
+	assert.Equal(t, []BeaconKey{k}, reloaded.GetBeaconKeys())
+	assert.Equal(t, "ip=10.0.0.1", reloaded.GetMetadata(k))
+
+	reloaded.PrepareDataForSending(k)
+	assert.Equal(t, "&et=60&na=later&et=1&na=action with\nnewline", reloaded.GetNextBeaconChunk(k, "", 1000, '&'))
+
+	reloaded.DeleteCacheEntry(k)
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+SEGMENT_FILE_EXTENSION))
+	assert.Empty(t, files)
+	assert.Empty(t, newTestPersistentCache(t, dir, now, 1000).GetBeaconKeys())
+}
+
+func TestPersistentCacheEnforcesLimitsOnLoad(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	k := NewBeaconKey(1, 0)
+
+	c := newTestPersistentCache(t, dir, now.Add(-2*time.Hour), 100)
+	c.AddEventData(k, now.Add(-2*time.Hour), "too old")
+	for i := 0; i < 6; i++ {
+		c.AddEventData(k, now.Add(time.Duration(i)*time.Second), "0123456789")
+	}
+	assert.Equal(t, int64(134), c.GetNumBytesInCache())
+
+	// above the upper bound of 100 bytes the oldest records are evicted down to the lower bound of 50 bytes
+	reloaded := newTestPersistentCache(t, dir, now, 100)
+	assert.Equal(t, int64(40), reloaded.GetNumBytesInCache())
+	assert.Equal(t, 2, len(reloaded.getCachedEntry(k).eventData))
+	assert.Equal(t, now.Add(4*time.Second).UnixNano(), reloaded.getCachedEntry(k).eventData[0].timestamp.UnixNano())
+
+	// evictions are persisted as well
+	assert.Equal(t, int64(40), newTestPersistentCache(t, dir, now, 100).GetNumBytesInCache())
+}
+
+func TestPersistentCacheKeepsRecordsBeforeTruncatedLine(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	k := NewBeaconKey(3, 1)
+
+	c := newTestPersistentCache(t, dir, now, 1000)
+	c.AddEventData(k, now, "et=18")
+
+	f, err := os.OpenFile(filepath.Join(dir, "3_1"+SEGMENT_FILE_EXTENSION), os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	f.WriteString("e 123 \"et=10&na=cut o")
+	f.Close()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "unrelated"+SEGMENT_FILE_EXTENSION), []byte("x"), 0600))
+
+	reloaded := newTestPersistentCache(t, dir, now, 1000)
+	assert.Equal(t, []BeaconKey{k}, reloaded.GetBeaconKeys())
+	assert.Equal(t, 1, len(reloaded.getCachedEntry(k).eventData))
+}
+
+func TestPersistentCacheKeepsRecordsOfIncompleteSends(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	k := NewBeaconKey(7, 2)
+
+	c := newTestPersistentCache(t, dir, now, 1000)
+	c.AddEventData(k, now, "first")
+	c.AddEventData(k, now, "second")
+
+	// the file is only rewritten once all prepared records were sent, a crash in between sends them again
+	c.PrepareDataForSending(k)
+	c.GetNextBeaconChunk(k, "", 1, '&')
+	c.RemoveChunkedData(k)
+	reloaded := newTestPersistentCache(t, dir, now, 1000)
+	reloaded.PrepareDataForSending(k)
+	assert.Equal(t, "&first&second", reloaded.GetNextBeaconChunk(k, "", 1000, '&'))
+}
+
+func TestPersistentCacheLimitsOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	c := newTestPersistentCache(t, dir, now, 1<<20)
+	for i := 0; i <= MAX_OPEN_SEGMENT_FILES; i++ {
+		c.AddEventData(NewBeaconKey(int32(i), 0), now, "event")
+	}
+	assert.Equal(t, MAX_OPEN_SEGMENT_FILES, c.store.lru.Len())
+
+	assert.NoError(t, c.Flush())
+	assert.Equal(t, 0, c.store.lru.Len())
+	assert.Equal(t, MAX_OPEN_SEGMENT_FILES+1, len(newTestPersistentCache(t, dir, now, 1<<20).GetBeaconKeys()))
+}
@@ -1,55 +1,127 @@
 package caching
 
 import (
+	"container/heap"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
-	log "github.com/sirupsen/logrus"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
 	"time"
 )
 
+// SpaceEvictionStrategy starts evicting once the cache grows above CacheSizeUpperBound and then removes the oldest
+// records of all beacons until the cache is at or below CacheSizeLowerBound
 type SpaceEvictionStrategy struct {
-	log           *log.Logger
-	cache         *BeaconCache
+	log           log.Logger
+	cache         BeaconCache
 	configuration *configuration.BeaconCacheConfiguration
 }
 
-func NewSpaceEvictionStrategy(log *log.Logger, cache *BeaconCache, configuration *configuration.BeaconCacheConfiguration) *SpaceEvictionStrategy {
+func NewSpaceEvictionStrategy(log log.Logger, cache BeaconCache, configuration *configuration.BeaconCacheConfiguration) *SpaceEvictionStrategy {
 	return &SpaceEvictionStrategy{log: log, cache: cache, configuration: configuration}
 }
 
-func (s *SpaceEvictionStrategy) execute() {
-	if s.cache.getNumBytesInCache() > s.configuration.CacheSizeUpperBound {
-		numRecordsRemoved := 0
-		for _, key := range s.cache.GetBeaconKeys() {
-			numRecordsRemoved += s.cache.evictRecordsByNumber(key, 1)
+func (s *SpaceEvictionStrategy) execute(scheduled bool) {
+	if s.cache.GetNumBytesInCache() <= s.configuration.CacheSizeUpperBound {
+		return
+	}
+	numRecordsRemoved, numBytesRemoved := s.evict()
+	s.log.WithFields(log.Fields{
+		"numRecordsRemoved": numRecordsRemoved,
+		"numBytesRemoved":   numBytesRemoved,
+		"numBytesInCache":   s.cache.GetNumBytesInCache(),
+	}).Info("SpaceEvictionStrategy removed records")
+}
+
+// evict removes the oldest records of all beacons until the cache is at or below CacheSizeLowerBound or no record
+// is left that can be evicted, records prepared for sending are kept. The records of each beacon are removed with a
+// single EvictRecordsByNumber call.
+func (s *SpaceEvictionStrategy) evict() (int, int64) {
+	oldest := &oldestRecordHeap{}
+	for _, key := range s.cache.GetBeaconKeys() {
+		if records := s.cache.GetEvictableRecords(key); len(records) > 0 {
+			*oldest = append(*oldest, &beaconRecords{key: key, records: records})
 		}
-		s.log.WithFields(log.Fields{"numRecordsRemoved": numRecordsRemoved}).Debug("SpaceEvictionStrategy removed records")
 	}
+	heap.Init(oldest)
+
+	// find how many of the oldest records of each beacon have to be removed
+	numBytesToRemove := s.cache.GetNumBytesInCache() - s.configuration.CacheSizeLowerBound
+	var selected []*beaconRecords
+	for oldest.Len() > 0 && numBytesToRemove > 0 {
+		beacon := (*oldest)[0]
+		if beacon.next == 0 {
+			selected = append(selected, beacon)
+		}
+		numBytesToRemove -= beacon.records[beacon.next].Size
+		beacon.next++
+		if beacon.next < len(beacon.records) {
+			heap.Fix(oldest, 0)
+		} else {
+			heap.Pop(oldest)
+		}
+	}
+
+	numRecordsRemoved := 0
+	numBytesRemoved := int64(0)
+	for _, beacon := range selected {
+		records, bytes := s.cache.EvictRecordsByNumber(beacon.key, beacon.next)
+		numRecordsRemoved += records
+		numBytesRemoved += bytes
+	}
+	return numRecordsRemoved, numBytesRemoved
+}
+
+// beaconRecords are the evictable records of a beacon, next is the first one that is not selected for eviction
+type beaconRecords struct {
+	key     BeaconKey
+	records []RecordInfo
+	next    int
+}
+
+// oldestRecordHeap implements heap.Interface with the beacon that has the oldest unselected record on top
+type oldestRecordHeap []*beaconRecords
+
+func (h oldestRecordHeap) Len() int { return len(h) }
+func (h oldestRecordHeap) Less(i, j int) bool {
+	return h[i].records[h[i].next].Timestamp.Before(h[j].records[h[j].next].Timestamp)
+}
+func (h oldestRecordHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *oldestRecordHeap) Push(x interface{}) { *h = append(*h, x.(*beaconRecords)) }
+func (h *oldestRecordHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
 }
 
 type TimeEvictionStrategy struct {
-	log              *log.Logger
-	cache            *BeaconCache
+	log              log.Logger
+	cache            BeaconCache
 	configuration    *configuration.BeaconCacheConfiguration
+	clock            providers.Clock
 	lastRunTimestamp time.Time
 }
 
-func NewTimeEvictionStrategy(log *log.Logger, cache *BeaconCache, configuration *configuration.BeaconCacheConfiguration) *TimeEvictionStrategy {
-	return &TimeEvictionStrategy{log: log, cache: cache, configuration: configuration}
+func NewTimeEvictionStrategy(log log.Logger, cache BeaconCache, configuration *configuration.BeaconCacheConfiguration, clock providers.Clock) *TimeEvictionStrategy {
+	return &TimeEvictionStrategy{log: log, cache: cache, configuration: configuration, clock: clock}
 }
 
-func (s *TimeEvictionStrategy) execute() {
-
-	if s.lastRunTimestamp.IsZero() {
-		s.lastRunTimestamp = time.Now()
+// execute evicts the records older than MaxRecordAge. Runs for added data happen at most once per
+// EvictionInterval, scheduled runs always evict so records live at most MaxRecordAge plus one interval.
+func (s *TimeEvictionStrategy) execute(scheduled bool) {
+	now := s.clock.Now()
+	if !scheduled && !s.lastRunTimestamp.IsZero() && now.Sub(s.lastRunTimestamp) < s.configuration.EvictionInterval {
+		return
 	}
+	s.lastRunTimestamp = now
 
-	if time.Now().Sub(s.lastRunTimestamp) > s.configuration.MaxRecordAge {
-		minAllowedAge := s.lastRunTimestamp.Add(-1 * s.configuration.MaxRecordAge)
-		numRecordsRemoved := 0
-		for _, key := range s.cache.GetBeaconKeys() {
-			numRecordsRemoved += s.cache.evictRecordsByAge(key, minAllowedAge)
-		}
-		s.log.WithFields(log.Fields{"numRecordsRemoved": numRecordsRemoved}).Debug("TimeEvictionStrategy removed records")
+	minAllowedAge := now.Add(-1 * s.configuration.MaxRecordAge)
+	numRecordsRemoved := 0
+	numBytesRemoved := int64(0)
+	for _, key := range s.cache.GetBeaconKeys() {
+		records, bytes := s.cache.EvictRecordsByAge(key, minAllowedAge)
+		numRecordsRemoved += records
+		numBytesRemoved += bytes
 	}
-	s.lastRunTimestamp = time.Now()
+	s.log.WithFields(log.Fields{"numRecordsRemoved": numRecordsRemoved, "numBytesRemoved": numBytesRemoved}).Debug("TimeEvictionStrategy removed records")
 }
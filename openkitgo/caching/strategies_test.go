@@ -0,0 +1,155 @@
+package caching
+
+import (
+	"fmt"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers/providerstest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTimeEvictionStrategyUsesClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := providerstest.NewFakeClock(start)
+
+	c := newBeaconCache(logger)
+	k := NewBeaconKey(1, 1)
+	c.AddEventData(k, start.Add(-2*time.Hour), "old")
+	c.AddEventData(k, start.Add(-50*time.Minute), "recent")
+	c.AddEventData(k, start, "new")
+
+	config := configuration.NewBeaconCacheConfiguration(time.Hour, 0, 1000)
+	s := NewTimeEvictionStrategy(logger, c, config, clock)
+
+	// records older than MaxRecordAge are evicted right away, the cutoff is an hour before now
+	s.execute(false)
+	assert.Equal(t, 2, len(c.getCachedEntry(k).eventData))
+
+	// runs for added data happen at most once per EvictionInterval, scheduled runs always evict
+	c.AddEventData(k, start.Add(-2*time.Hour), "late")
+	clock.Advance(30 * time.Second)
+	s.execute(false)
+	assert.Equal(t, 3, len(c.getCachedEntry(k).eventData))
+	s.execute(true)
+	assert.Equal(t, 2, len(c.getCachedEntry(k).eventData))
+
+	c.AddEventData(k, start.Add(-2*time.Hour), "late")
+
+	clock.Advance(11 * time.Minute)
+	s.execute(false)
+	assert.Equal(t, 1, len(c.getCachedEntry(k).eventData))
+	assert.Equal(t, "new", c.getCachedEntry(k).eventData[0].data)
+}
+
+func TestSpaceEvictionStrategyKeepsCacheBelowUpperBound(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newBeaconCache(logger)
+	k1 := NewBeaconKey(1, 0)
+	k2 := NewBeaconKey(2, 0)
+	for i := 0; i < 5; i++ {
+		c.AddEventData(k1, start.Add(time.Duration(i)*time.Second), "0123456789")
+	}
+
+	config := configuration.NewBeaconCacheConfiguration(time.Hour, 40, 100)
+	s := NewSpaceEvictionStrategy(logger, c, config)
+
+	s.execute(false)
+	assert.Equal(t, int64(100), c.GetNumBytesInCache(), "nothing is evicted at the upper bound")
+
+	c.AddActionData(k2, start.Add(1500*time.Millisecond), "0123456789")
+	c.AddEventData(k2, start.Add(10*time.Second), "0123456789")
+	numRecordsRemoved, numBytesRemoved := s.evict()
+
+	assert.Equal(t, 5, numRecordsRemoved)
+	assert.Equal(t, int64(100), numBytesRemoved)
+	assert.Equal(t, int64(40), c.GetNumBytesInCache())
+	// the oldest records of both keys are evicted first
+	assert.Equal(t, 1, len(c.getCachedEntry(k1).eventData))
+	assert.Equal(t, start.Add(4*time.Second), c.getCachedEntry(k1).eventData[0].timestamp)
+	assert.Empty(t, c.getCachedEntry(k2).actionData)
+	assert.Equal(t, 1, len(c.getCachedEntry(k2).eventData))
+}
+
+func TestSpaceEvictionStrategyKeepsPreparedRecords(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newBeaconCache(logger)
+	k := NewBeaconKey(1, 0)
+	c.AddEventData(k, start, "prepared")
+	c.PrepareDataForSending(k)
+	c.AddEventData(k, start.Add(time.Second), "0123456789")
+
+	config := configuration.NewBeaconCacheConfiguration(time.Hour, 0, 10)
+	s := NewSpaceEvictionStrategy(logger, c, config)
+	s.execute(false)
+
+	assert.Equal(t, int64(0), c.GetNumBytesInCache())
+	assert.True(t, c.HasDataForSending(k))
+	assert.Equal(t, "&prepared", c.GetNextBeaconChunk(k, "", 1024, '&'))
+}
+
+// evictionCountingCache counts the EvictRecordsByNumber calls per key
+type evictionCountingCache struct {
+	BeaconCache
+	calls map[BeaconKey]int
+}
+
+func (c *evictionCountingCache) EvictRecordsByNumber(key BeaconKey, numRecords int) (int, int64) {
+	c.calls[key]++
+	return c.BeaconCache.EvictRecordsByNumber(key, numRecords)
+}
+
+func TestSpaceEvictionStrategyEvictsEachKeyOnce(t *testing.T) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := &evictionCountingCache{BeaconCache: newBeaconCache(logger), calls: map[BeaconKey]int{}}
+	k1 := NewBeaconKey(1, 0)
+	k2 := NewBeaconKey(2, 0)
+	k3 := NewBeaconKey(3, 0)
+	for i := 0; i < 5; i++ {
+		c.AddEventData(k1, start.Add(time.Duration(2*i)*time.Second), "0123456789")
+		c.AddActionData(k2, start.Add(time.Duration(2*i+1)*time.Second), "0123456789")
+		c.AddEventData(k3, start.Add(time.Hour), "0123456789")
+	}
+
+	config := configuration.NewBeaconCacheConfiguration(time.Hour, 200, 280)
+	s := NewSpaceEvictionStrategy(logger, c, config)
+	numRecordsRemoved, numBytesRemoved := s.evict()
+
+	assert.Equal(t, 5, numRecordsRemoved)
+	assert.Equal(t, int64(100), numBytesRemoved)
+	assert.Equal(t, map[BeaconKey]int{k1: 1, k2: 1}, c.calls, "keys with nothing to evict are not touched")
+	assert.Equal(t, int64(200), c.GetNumBytesInCache())
+	records := c.GetEvictableRecords(k1)
+	assert.Equal(t, 2, len(records))
+	assert.Equal(t, start.Add(6*time.Second), records[0].Timestamp)
+	assert.Equal(t, 3, len(c.GetEvictableRecords(k2)))
+}
+
+func BenchmarkSpaceEvictionStrategy(b *testing.B) {
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	nop := log.NewNopLogger()
+
+	for _, numSessions := range []int{1000, 10000} {
+		b.Run(fmt.Sprintf("sessions=%d", numSessions), func(b *testing.B) {
+			const recordsPerSession = 20
+			// every record is 20 bytes, so the cache holds twice the upper bound and is evicted down to half of it
+			upperBound := int64(numSessions*recordsPerSession*20) / 2
+			config := configuration.NewBeaconCacheConfiguration(time.Hour, upperBound/2, upperBound)
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				c := newBeaconCache(nop)
+				for r := 0; r < recordsPerSession; r++ {
+					for s := 0; s < numSessions; s++ {
+						c.AddEventData(NewBeaconKey(int32(s), 0), start.Add(time.Duration(r*numSessions+s)), "0123456789")
+					}
+				}
+				s := NewSpaceEvictionStrategy(nop, c, config)
+				b.StartTimer()
+
+				s.execute(false)
+			}
+		})
+	}
+}
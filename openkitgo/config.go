@@ -0,0 +1,79 @@
+package openkitgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/core"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const ENV_PREFIX = "OPENKIT_"
+
+// NewOpenKitBuilderFromEnv creates a builder from OPENKIT_ environment variables, e.g. OPENKIT_ENDPOINT_URL,
+// OPENKIT_APPLICATION_ID and OPENKIT_DEVICE_ID. Unknown OPENKIT_ variables and invalid values are returned as
+// core.ValidationErrors. With* methods called on the returned builder override the loaded values.
+func NewOpenKitBuilderFromEnv() (interfaces.OpenKitBuilder, error) {
+	settings := map[string]string{}
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, ENV_PREFIX) {
+			continue
+		}
+		kv := strings.SplitN(env, "=", 2)
+		settings[strings.ToLower(strings.TrimPrefix(kv[0], ENV_PREFIX))] = kv[1]
+	}
+	return newOpenKitBuilderFromSettings(settings)
+}
+
+// NewOpenKitBuilderFromFile creates a builder from a flat JSON or YAML file, chosen by the file extension.
+// The keys are the names of the environment variables without the OPENKIT_ prefix in lower case,
+// e.g. endpoint_url. With* methods called on the returned builder override the loaded values.
+func NewOpenKitBuilderFromFile(path string) (interfaces.OpenKitBuilder, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	default:
+		return nil, fmt.Errorf("unsupported configuration file %s, use .json, .yaml or .yml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	settings := map[string]string{}
+	var errs core.ValidationErrors
+	for key, value := range values {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}, nil:
+			errs = append(errs, &core.ValidationError{Field: key, Reason: "must be a string, number or boolean"})
+		default:
+			settings[key] = fmt.Sprint(value)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return newOpenKitBuilderFromSettings(settings)
+}
+
+func newOpenKitBuilderFromSettings(settings map[string]string) (interfaces.OpenKitBuilder, error) {
+	b, err := core.NewOpenKitBuilderFromSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,139 @@
+package openkitgo
+
+import (
+	"errors"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/core"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	dir, err := ioutil.TempDir("", "openkit")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, name)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func validationFields(err error) []string {
+	var errs core.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	return fields
+}
+
+func TestNewOpenKitBuilderFromJSONFile(t *testing.T) {
+	path := writeConfigFile(t, "openkit.json", `{
+		"endpoint_url": "https://tenant.live.dynatrace.com/mbeacon",
+		"application_id": "my-app",
+		"device_id": 42,
+		"application_name": "from file",
+		"data_collection_level": "performance",
+		"beacon_cache_max_record_age": "1h",
+		"insecure_skip_verify": false
+	}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	b, err := NewOpenKitBuilderFromFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, b.Validate())
+	assert.Equal(t, "my-app", b.(*core.OpenKitBuilder).ApplicationID())
+	assert.Equal(t, "from file", b.(*core.OpenKitBuilder).ApplicationName())
+
+	// values set in code win over the file
+	b.WithApplicationName("from code")
+	assert.Equal(t, "from code", b.(*core.OpenKitBuilder).ApplicationName())
+}
+
+func TestNewOpenKitBuilderFromYAMLFile(t *testing.T) {
+	path := writeConfigFile(t, "openkit.yaml", `
+endpoint_url: https://tenant.live.dynatrace.com/mbeacon
+application_id: my-app
+device_id: 42
+crash_reporting_level: 0
+log_level: warn
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	b, err := NewOpenKitBuilderFromFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "my-app", b.(*core.OpenKitBuilder).ApplicationID())
+}
+
+func TestLogLevelSettingAppliesToLogger(t *testing.T) {
+	path := writeConfigFile(t, "openkit.yaml", `
+endpoint_url: https://tenant.live.dynatrace.com/mbeacon
+application_id: my-app
+device_id: 42
+log_level: warn
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	b, err := NewOpenKitBuilderFromFile(path)
+	assert.NoError(t, err)
+
+	// the level of the file applies to a logger set afterwards
+	logger := logrus.New()
+	b.WithLogger(logging.NewLogrusLogger(logger))
+	assert.Equal(t, logrus.WarnLevel, logger.GetLevel())
+
+	// without a configured level the logger keeps its own
+	logger = logrus.New()
+	logger.SetLevel(logrus.DebugLevel)
+	NewOpenKitBuilder("https://tenant.live.dynatrace.com/mbeacon", "my-app", 42).WithLogger(logging.NewLogrusLogger(logger))
+	assert.Equal(t, logrus.DebugLevel, logger.GetLevel())
+}
+
+func TestNewOpenKitBuilderFromFileReportsInvalidKeys(t *testing.T) {
+	path := writeConfigFile(t, "openkit.yml", `
+endpoint_url: https://tenant.live.dynatrace.com/mbeacon
+application_id: my-app
+device_id: " "
+data_collection_level: everything
+unknown_key: 1
+`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, err := NewOpenKitBuilderFromFile(path)
+	assert.Equal(t, []string{"data_collection_level", "device_id", "unknown_key"}, validationFields(err))
+
+	path = writeConfigFile(t, "openkit.json", `{"application_id": "my-app"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	_, err = NewOpenKitBuilderFromFile(path)
+	assert.Equal(t, []string{"endpoint_url", "device_id"}, validationFields(err))
+}
+
+func TestNewOpenKitBuilderFromEnv(t *testing.T) {
+	env := map[string]string{
+		"OPENKIT_ENDPOINT_URL":          "https://tenant.live.dynatrace.com/mbeacon",
+		"OPENKIT_APPLICATION_ID":        "env-app",
+		"OPENKIT_DEVICE_ID":             "7",
+		"OPENKIT_DATA_COLLECTION_LEVEL": "2",
+		"OPENKIT_REQUEST_TIMEOUT":       "5s",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	b, err := NewOpenKitBuilderFromEnv()
+	assert.NoError(t, err)
+	assert.NoError(t, b.Validate())
+	assert.Equal(t, "env-app", b.(*core.OpenKitBuilder).ApplicationID())
+
+	os.Setenv("OPENKIT_DATA_COLECTION_LEVEL", "2")
+	defer os.Unsetenv("OPENKIT_DATA_COLECTION_LEVEL")
+	_, err = NewOpenKitBuilderFromEnv()
+	assert.Equal(t, []string{"data_colection_level"}, validationFields(err))
+}
@@ -20,8 +20,8 @@ func NewBeaconConfiguration(
 ) *BeaconConfiguration {
 
 	h := &HttpClientConfiguration{
-		ServerID:  serverID,
-		Transport: openKitConfiguration.Transport,
+		ServerID: serverID,
+		Client:   openKitConfiguration.HttpClient,
 	}
 	return &BeaconConfiguration{
 		OpenKitConfiguration:    openKitConfiguration,
@@ -8,6 +8,8 @@ type BeaconCacheConfiguration struct {
 	MaxRecordAge        time.Duration
 	CacheSizeLowerBound int64
 	CacheSizeUpperBound int64
+	// EvictionInterval is how often records older than MaxRecordAge are evicted, even if no data is added
+	EvictionInterval time.Duration
 }
 
 func NewBeaconCacheConfiguration(maxRecordAge time.Duration, cacheSizeLowerBound int64, cacheSizeUpperBound int64) *BeaconCacheConfiguration {
@@ -15,5 +17,6 @@ func NewBeaconCacheConfiguration(maxRecordAge time.Duration, cacheSizeLowerBound
 		MaxRecordAge:        maxRecordAge,
 		CacheSizeLowerBound: cacheSizeLowerBound,
 		CacheSizeUpperBound: cacheSizeUpperBound,
+		EvictionInterval:    DEFAULT_EVICTION_INTERVAL,
 	}
 }
@@ -1,11 +1,21 @@
 package configuration
 
-import "net/http"
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"net/http"
+	"time"
+)
 
 type HttpClientConfiguration struct {
 	BaseURL       string
 	ServerID      int
 	ApplicationID string
 	Transport     *http.Transport
-	Technology    string
+	// Client is shared by all requests, if nil a client using Transport is created
+	Client         *http.Client
+	Technology     string
+	RetryPolicy    RetryPolicy
+	RequestTimeout time.Duration
+	// RandomNumberGenerator draws the jitter of retries, a time seeded one is used if nil
+	RandomNumberGenerator providers.RandomNumberGenerator
 }
@@ -9,6 +9,7 @@ const (
 	ENCODING_CHARSET                       = "UTF-8"
 	RESERVED_CHARACTERS                    = "_"
 	DEFAULT_MAX_RECORD_AGE                 = 105 * time.Minute
+	DEFAULT_EVICTION_INTERVAL              = time.Minute
 	DEFAULT_UPPER_MEMORY_BOUNDARY_IN_BYTES = int64(100 * 1024 * 1024)
 	DEFAULT_LOWER_MEMORY_BOUNDARY_IN_BYTES = int64(80 * 1024 * 1024)
 	DEFAULT_DATA_COLLECTION_LEVEL          = DATA_USER_BEHAVIOR
@@ -28,5 +29,5 @@ type OpenKitConfiguration struct {
 	Manufacturer                string
 	ModelID                     string
 	DefaultServerID             int
-	Transport                   *http.Transport
+	HttpClient                  *http.Client
 }
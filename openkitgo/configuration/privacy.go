@@ -1,5 +1,7 @@
 package configuration
 
+import "sync"
+
 type DataCollectionLevel int
 
 const (
@@ -16,45 +18,86 @@ const (
 	CRASH_OPT_IN_CRASHES  CrashReportingLevel = 2
 )
 
+// PrivacyConfiguration holds the privacy levels, they can be changed while sessions are running
 type PrivacyConfiguration struct {
-	DataCollectionLevel DataCollectionLevel
-	CrashReportingLevel CrashReportingLevel
+	dataCollectionLevel DataCollectionLevel
+	crashReportingLevel CrashReportingLevel
+	mutex               sync.RWMutex
+}
+
+func NewPrivacyConfiguration(dataCollectionLevel DataCollectionLevel, crashReportingLevel CrashReportingLevel) *PrivacyConfiguration {
+	return &PrivacyConfiguration{dataCollectionLevel: dataCollectionLevel, crashReportingLevel: crashReportingLevel}
+}
+
+// Copy returns an independent PrivacyConfiguration with the current levels
+func (c *PrivacyConfiguration) Copy() *PrivacyConfiguration {
+	return NewPrivacyConfiguration(c.GetDataCollectionLevel(), c.GetCrashReportingLevel())
+}
+
+func (c *PrivacyConfiguration) GetDataCollectionLevel() DataCollectionLevel {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.dataCollectionLevel
+}
+
+func (c *PrivacyConfiguration) GetCrashReportingLevel() CrashReportingLevel {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.crashReportingLevel
+}
+
+// SetDataCollectionLevel changes the level and returns the previous one
+func (c *PrivacyConfiguration) SetDataCollectionLevel(level DataCollectionLevel) DataCollectionLevel {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	previous := c.dataCollectionLevel
+	c.dataCollectionLevel = level
+	return previous
+}
+
+// SetCrashReportingLevel changes the level and returns the previous one
+func (c *PrivacyConfiguration) SetCrashReportingLevel(level CrashReportingLevel) CrashReportingLevel {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	previous := c.crashReportingLevel
+	c.crashReportingLevel = level
+	return previous
 }
 
 func (c *PrivacyConfiguration) IsDeviceIDSendingAllowed() bool {
-	return c.DataCollectionLevel == DATA_USER_BEHAVIOR
+	return c.GetDataCollectionLevel() == DATA_USER_BEHAVIOR
 }
 
 func (c *PrivacyConfiguration) IsSessionNumberReportingAllowed() bool {
-	return c.DataCollectionLevel == DATA_USER_BEHAVIOR
+	return c.GetDataCollectionLevel() == DATA_USER_BEHAVIOR
 }
 
 func (c *PrivacyConfiguration) IsWebRequestTracingAllowed() bool {
-	return c.DataCollectionLevel != DATA_OFF
+	return c.GetDataCollectionLevel() != DATA_OFF
 }
 
 func (c *PrivacyConfiguration) IsSessionReportingAllowed() bool {
-	return c.DataCollectionLevel != DATA_OFF
+	return c.GetDataCollectionLevel() != DATA_OFF
 }
 func (c *PrivacyConfiguration) IsActionReportingAllowed() bool {
-	return c.DataCollectionLevel != DATA_OFF
+	return c.GetDataCollectionLevel() != DATA_OFF
 }
 
 func (c *PrivacyConfiguration) IsValueReportingAllowed() bool {
-	return c.DataCollectionLevel == DATA_USER_BEHAVIOR
+	return c.GetDataCollectionLevel() == DATA_USER_BEHAVIOR
 }
 
 func (c *PrivacyConfiguration) IsEventReportingAllowed() bool {
-	return c.DataCollectionLevel == DATA_USER_BEHAVIOR
+	return c.GetDataCollectionLevel() == DATA_USER_BEHAVIOR
 }
 
 func (c *PrivacyConfiguration) IsErrorReportingAllowed() bool {
-	return c.DataCollectionLevel != DATA_OFF
+	return c.GetDataCollectionLevel() != DATA_OFF
 }
 func (c *PrivacyConfiguration) IsCrashReportingAllowed() bool {
-	return c.CrashReportingLevel == CRASH_OPT_IN_CRASHES
+	return c.GetCrashReportingLevel() == CRASH_OPT_IN_CRASHES
 }
 
-func (c *PrivacyConfiguration) isUserIdentificationAllowed() bool {
-	return c.DataCollectionLevel == DATA_USER_BEHAVIOR
+func (c *PrivacyConfiguration) IsUserIdentificationAllowed() bool {
+	return c.GetDataCollectionLevel() == DATA_USER_BEHAVIOR
 }
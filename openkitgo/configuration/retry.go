@@ -0,0 +1,38 @@
+package configuration
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"math"
+	"time"
+)
+
+// RetryPolicy controls how often and how fast failed requests to the beacon endpoint are retried.
+// New session requests are retried on connection errors and 5xx responses, status requests on connection errors
+// and all error responses except 429.
+type RetryPolicy struct {
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Jitter is the fraction of the backoff that is randomized, between 0 and 1
+	Jitter float64
+	// RetryBeacons retries beacons as well. A beacon whose response timed out may have been accepted already,
+	// so retrying it can send data twice. Without retries failed beacons are sent again in the next send interval.
+	RetryBeacons bool
+}
+
+// Backoff returns the time to wait before the given retry, starting at 0. The jitter is drawn from random,
+// it is not applied if random is nil.
+func (p RetryPolicy) Backoff(retry int, random providers.RandomNumberGenerator) time.Duration {
+	backoff := p.BaseBackoff
+	for i := 0; i < retry && (p.MaxBackoff <= 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if p.Jitter > 0 && random != nil {
+		fraction := float64(random.NextPositiveInt64()) / float64(math.MaxInt64)
+		backoff -= time.Duration(fraction * p.Jitter * float64(backoff))
+	}
+	return backoff
+}
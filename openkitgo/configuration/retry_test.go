@@ -0,0 +1,32 @@
+package configuration
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	assert.Equal(t, 100*time.Millisecond, p.Backoff(0, nil))
+	assert.Equal(t, 200*time.Millisecond, p.Backoff(1, nil))
+	assert.Equal(t, 800*time.Millisecond, p.Backoff(3, nil))
+	assert.Equal(t, time.Second, p.Backoff(4, nil))
+	assert.Equal(t, time.Second, p.Backoff(100, nil))
+
+	p.Jitter = 0.5
+	assert.Equal(t, 200*time.Millisecond, p.Backoff(1, nil), "no jitter without a random number generator")
+
+	random := providers.NewRandomNumberGenerator(rand.NewSource(42))
+	for i := 0; i < 100; i++ {
+		backoff := p.Backoff(1, random)
+		assert.True(t, backoff > 100*time.Millisecond && backoff <= 200*time.Millisecond)
+	}
+
+	// the same seed gives the same backoffs
+	first := p.Backoff(1, providers.NewRandomNumberGenerator(rand.NewSource(7)))
+	assert.Equal(t, first, p.Backoff(1, providers.NewRandomNumberGenerator(rand.NewSource(7))))
+}
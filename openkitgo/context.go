@@ -0,0 +1,61 @@
+package openkitgo
+
+import (
+	"context"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/core"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+)
+
+type contextKey int
+
+const (
+	actionContextKey contextKey = iota
+	sessionContextKey
+)
+
+// ContextWithAction returns a copy of ctx that carries action
+func ContextWithAction(ctx context.Context, action interfaces.Action) context.Context {
+	return context.WithValue(ctx, actionContextKey, action)
+}
+
+// ActionFromContext returns the action carried by ctx, or a NullAction if there is none
+func ActionFromContext(ctx context.Context) interfaces.Action {
+	if action, ok := ctx.Value(actionContextKey).(interfaces.Action); ok && action != nil {
+		return action
+	}
+	return core.NewNullAction()
+}
+
+// ContextWithSession returns a copy of ctx that carries session
+func ContextWithSession(ctx context.Context, session interfaces.Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey, session)
+}
+
+// SessionFromContext returns the session carried by ctx, or a NullSession if there is none
+func SessionFromContext(ctx context.Context) interfaces.Session {
+	if session, ok := ctx.Value(sessionContextKey).(interfaces.Session); ok && session != nil {
+		return session
+	}
+	return core.NewNullSession()
+}
+
+// EnterActionContext enters a child of the action carried by ctx, or a top level action
+// on the session carried by ctx if there is no action, and returns a context carrying the new action
+func EnterActionContext(ctx context.Context, actionName string) (context.Context, interfaces.Action) {
+	var action interfaces.Action
+	if parent, ok := ctx.Value(actionContextKey).(interfaces.Action); ok && parent != nil {
+		action = parent.EnterAction(actionName)
+	} else {
+		action = SessionFromContext(ctx).EnterAction(actionName)
+	}
+	return ContextWithAction(ctx, action), action
+}
+
+// TraceWebRequestContext traces a web request on the action carried by ctx, or on the session
+// carried by ctx if there is no action
+func TraceWebRequestContext(ctx context.Context, url string) interfaces.WebRequestTracer {
+	if action, ok := ctx.Value(actionContextKey).(interfaces.Action); ok && action != nil {
+		return action.TraceWebRequest(url)
+	}
+	return SessionFromContext(ctx).TraceWebRequest(url)
+}
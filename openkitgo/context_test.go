@@ -0,0 +1,55 @@
+package openkitgo
+
+import (
+	"context"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/core"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeAction struct {
+	interfaces.Action
+	name   string
+	parent *fakeAction
+}
+
+func (a *fakeAction) EnterAction(actionName string) interfaces.Action {
+	return &fakeAction{name: actionName, parent: a}
+}
+
+type fakeSession struct {
+	interfaces.Session
+}
+
+func (s *fakeSession) EnterAction(actionName string) interfaces.Action {
+	return &fakeAction{name: actionName}
+}
+
+func TestFromContextFallsBackToNullObjects(t *testing.T) {
+	ctx := context.Background()
+
+	assert.IsType(t, core.NullAction{}, ActionFromContext(ctx))
+	assert.IsType(t, &core.NullSession{}, SessionFromContext(ctx))
+
+	ctx, action := EnterActionContext(ctx, "orphan")
+	assert.IsType(t, core.NullAction{}, action)
+	assert.Equal(t, action, ActionFromContext(ctx))
+}
+
+func TestEnterActionContext(t *testing.T) {
+	session := &fakeSession{}
+	ctx := ContextWithSession(context.Background(), session)
+	assert.Equal(t, session, SessionFromContext(ctx))
+
+	ctx, root := EnterActionContext(ctx, "root")
+	assert.Equal(t, "root", root.(*fakeAction).name)
+	assert.Nil(t, root.(*fakeAction).parent)
+
+	childCtx, child := EnterActionContext(ctx, "child")
+	assert.Equal(t, "child", child.(*fakeAction).name)
+	assert.Equal(t, root, child.(*fakeAction).parent)
+
+	assert.Equal(t, root, ActionFromContext(ctx))
+	assert.Equal(t, child, ActionFromContext(childCtx))
+}
@@ -2,13 +2,13 @@ package core
 
 import (
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
-	log "github.com/sirupsen/logrus"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"sync"
 	"time"
 )
 
 type Action struct {
-	log             *log.Logger
+	log             log.Logger
 	parent          OpenKitComposite
 	parentAction    interfaces.Action
 	parentActionID  int
@@ -63,7 +63,7 @@ func (a *Action) getActionID() int {
 	return int(a.id)
 }
 
-func NewAction(log *log.Logger, parent OpenKitComposite, parentAction interfaces.Action, name string, beacon *Beacon, startTime time.Time) *Action {
+func NewAction(log log.Logger, parent OpenKitComposite, parentAction interfaces.Action, name string, beacon *Beacon, startTime time.Time) *Action {
 
 	return &Action{
 		log:             log,
@@ -86,11 +86,11 @@ func (a *Action) closeAt(timestamp time.Time) {
 }
 
 func (a *Action) close() {
-	a.closeAt(time.Now())
+	a.closeAt(a.beacon.clock.Now())
 }
 
 func (a *Action) ReportEvent(eventName string) interfaces.Action {
-	return a.ReportEventAt(eventName, time.Now())
+	return a.ReportEventAt(eventName, a.beacon.clock.Now())
 }
 
 func (a *Action) ReportEventAt(eventName string, timestamp time.Time) interfaces.Action {
@@ -109,7 +109,7 @@ func (a *Action) ReportEventAt(eventName string, timestamp time.Time) interfaces
 }
 
 func (a *Action) ReportValue(valueName string, value interface{}) interfaces.Action {
-	return a.ReportValueAt(valueName, value, time.Now())
+	return a.ReportValueAt(valueName, value, a.beacon.clock.Now())
 }
 
 func (a *Action) ReportValueAt(valueName string, value interface{}, timestamp time.Time) interfaces.Action {
@@ -121,7 +121,7 @@ func (a *Action) ReportValueAt(valueName string, value interface{}, timestamp ti
 }
 
 func (a *Action) ReportError(errorName string, causeName string, causeDescription string, causeStack string) interfaces.Action {
-	return a.ReportErrorAt(errorName, causeName, causeDescription, causeStack, time.Now())
+	return a.ReportErrorAt(errorName, causeName, causeDescription, causeStack, a.beacon.clock.Now())
 }
 
 func (a *Action) ReportErrorAt(errorName string, causeName string, causeDescription string, causeStack string, timestamp time.Time) interfaces.Action {
@@ -133,7 +133,7 @@ func (a *Action) ReportErrorAt(errorName string, causeName string, causeDescript
 }
 
 func (a *Action) LeaveAction() interfaces.Action {
-	return a.LeaveActionAt(time.Now())
+	return a.LeaveActionAt(a.beacon.clock.Now())
 }
 
 func (a *Action) LeaveActionAt(timestamp time.Time) interfaces.Action {
@@ -143,7 +143,7 @@ func (a *Action) LeaveActionAt(timestamp time.Time) interfaces.Action {
 }
 
 func (a *Action) CancelAction() interfaces.Action {
-	return a.CancelActionAt(time.Now())
+	return a.CancelActionAt(a.beacon.clock.Now())
 }
 
 func (a *Action) CancelActionAt(timestamp time.Time) interfaces.Action {
@@ -189,11 +189,11 @@ func (a *Action) GetDuration() time.Duration {
 	if a.actionLeft {
 		return a.endTime.Sub(a.startTime)
 	}
-	return time.Now().Sub(a.startTime)
+	return a.beacon.clock.Now().Sub(a.startTime)
 }
 
 func (a *Action) TraceWebRequest(url string) interfaces.WebRequestTracer {
-	return a.TraceWebRequestAt(url, time.Now())
+	return a.TraceWebRequestAt(url, a.beacon.clock.Now())
 }
 
 func (a *Action) TraceWebRequestAt(url string, timestamp time.Time) interfaces.WebRequestTracer {
@@ -208,7 +208,7 @@ func (a *Action) TraceWebRequestAt(url string, timestamp time.Time) interfaces.W
 }
 
 func (a *Action) EnterAction(actionName string) interfaces.Action {
-	return a.EnterActionAt(actionName, time.Now())
+	return a.EnterActionAt(actionName, a.beacon.clock.Now())
 }
 
 func (a *Action) EnterActionAt(actionName string, timestamp time.Time) interfaces.Action {
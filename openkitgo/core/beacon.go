@@ -5,12 +5,14 @@ import (
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/protocol"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/redaction"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/utils"
-	log "github.com/sirupsen/logrus"
-	"math/rand"
+	"math"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -80,21 +82,21 @@ const (
 	BEACON_DATA_DELIMITER = '&'
 )
 
-type EventType int
+type EventType = interfaces.EventType
 
 const (
-	ACTION        EventType = 1
-	VALUE_STRING  EventType = 11
-	VALUE_INT     EventType = 12
-	VALUE_DOUBLE  EventType = 13
-	NAMED_EVENT   EventType = 10
-	SESSION_START EventType = 18
-	SESSION_END   EventType = 19
-	WEB_REQUEST   EventType = 30
-	ERROR         EventType = 40
-	EXCEPTION     EventType = 42
-	CRASH         EventType = 50
-	IDENTIFY_USER EventType = 60
+	ACTION        = interfaces.ACTION
+	VALUE_STRING  = interfaces.VALUE_STRING
+	VALUE_INT     = interfaces.VALUE_INT
+	VALUE_DOUBLE  = interfaces.VALUE_DOUBLE
+	NAMED_EVENT   = interfaces.NAMED_EVENT
+	SESSION_START = interfaces.SESSION_START
+	SESSION_END   = interfaces.SESSION_END
+	WEB_REQUEST   = interfaces.WEB_REQUEST
+	ERROR         = interfaces.ERROR
+	EXCEPTION     = interfaces.EXCEPTION
+	CRASH         = interfaces.CRASH
+	IDENTIFY_USER = interfaces.IDENTIFY_USER
 )
 
 type Beacon struct {
@@ -104,19 +106,24 @@ type Beacon struct {
 	sessionStartTime   time.Time
 	deviceID           int64
 	clientIPAddress    string
+	// randomDeviceID is sent instead of deviceID while the data collection level does not allow it
+	randomDeviceID int64
 
-	immutableBasicBeaconData string
-	configuration            *configuration.BeaconConfiguration
-	trafficControlValue      int
-	log                      *log.Logger
-	cache                    *caching.BeaconCache
-	sessionIDProvider        *providers.SessionIDProvider
+	configuration       *configuration.BeaconConfiguration
+	trafficControlValue int
+	log                 log.Logger
+	cache               caching.BeaconCache
+	sessionIDProvider   providers.SessionIDProvider
+	threadIDProvider    providers.ThreadIDProvider
+	clock               providers.Clock
+	redactor            *redaction.Redactor
+	beforeSend          interfaces.BeforeSendFunc
 }
 
 func NewBeacon(
-	log *log.Logger,
-	beaconCache *caching.BeaconCache,
-	sessionIDProvider *providers.SessionIDProvider,
+	log log.Logger,
+	beaconCache caching.BeaconCache,
+	sessionIDProvider providers.SessionIDProvider,
 	sessionProxy *SessionProxy,
 	beaconConfiguration *configuration.BeaconConfiguration,
 	sessionStartTime time.Time,
@@ -133,20 +140,25 @@ func NewBeacon(
 		key:                 caching.NewBeaconKey(sessionNumber, sessionSequenceNumber),
 		sessionStartTime:    sessionStartTime,
 		deviceID:            deviceID,
-		clientIPAddress:     ipAddress,
+		clientIPAddress:     sessionProxy.redactor.RedactClientIP(ipAddress),
+		randomDeviceID:      sessionProxy.randomNumberGenerator.NextPositiveInt64(),
 		configuration:       beaconConfiguration,
-		trafficControlValue: rand.Intn(100),
+		trafficControlValue: sessionProxy.randomNumberGenerator.NextPercentageValue(),
 		log:                 log,
 		cache:               beaconCache,
 		sessionIDProvider:   sessionIDProvider,
+		threadIDProvider:    sessionProxy.threadIDProvider,
+		clock:               sessionProxy.clock,
+		redactor:            sessionProxy.redactor,
+		beforeSend:          sessionProxy.beforeSend,
 	}
-	b.immutableBasicBeaconData = b.createImmutableBasicBeaconData()
+	caching.SetMetadata(b.cache, b.key, b.createMetadata())
 
 	return b
 
 }
 func (b *Beacon) EndSession() {
-	b.EndSessionAt(time.Now())
+	b.EndSessionAt(b.clock.Now())
 }
 
 func (b *Beacon) CreateID() int32 {
@@ -172,7 +184,7 @@ func (b *Beacon) CreateTag(parentActionID int, tracerSeqNo int) string {
 	builder.WriteString(TAG_PREFIX)
 	builder.WriteString(fmt.Sprintf("_%d", protocol.PROTOCOL_VERSION))
 	builder.WriteString(fmt.Sprintf("_%d", serverID))
-	builder.WriteString(fmt.Sprintf("_%d", b.deviceID))
+	builder.WriteString(fmt.Sprintf("_%d", b.getDeviceID()))
 	builder.WriteString(fmt.Sprintf("_%d", b.GetSessionNumber()))
 	if b.GetVisitStoreVersion() > 1 {
 		builder.WriteString(fmt.Sprintf("-%d", b.key.BeaconSeqNo))
@@ -186,7 +198,7 @@ func (b *Beacon) CreateTag(parentActionID int, tracerSeqNo int) string {
 }
 
 func (b *Beacon) AddAction(action *Action) {
-	b.AddActionAt(action, time.Now())
+	b.AddActionAt(action, b.clock.Now())
 }
 
 func (b *Beacon) AddActionAt(action *Action, timestamp time.Time) {
@@ -195,7 +207,7 @@ func (b *Beacon) AddActionAt(action *Action, timestamp time.Time) {
 		return
 	}
 
-	if !b.configuration.PrivacyConfiguration.IsActionReportingAllowed() {
+	if !b.isEventTypeAllowed(ACTION) {
 		return
 	}
 
@@ -203,16 +215,27 @@ func (b *Beacon) AddActionAt(action *Action, timestamp time.Time) {
 		return
 	}
 
+	event := b.applyBeforeSend(&interfaces.Event{
+		Type:           ACTION,
+		Name:           action.name,
+		ParentActionID: int(action.parentActionID),
+		StartTime:      action.startTime,
+		EndTime:        action.endTime,
+	})
+	if event == nil {
+		return
+	}
+
 	var builder strings.Builder
 
-	b.buildBasicEventData(&builder, ACTION, action.name)
+	b.buildBasicEventData(&builder, ACTION, b.redact(redaction.FIELD_ACTION_NAME, event.Name))
 
 	b.addKeyValuePair(&builder, BEACON_KEY_ACTION_ID, action.id)
-	b.addKeyValuePair(&builder, BEACON_KEY_PARENT_ACTION_ID, action.parentActionID)
+	b.addKeyValuePair(&builder, BEACON_KEY_PARENT_ACTION_ID, event.ParentActionID)
 	b.addKeyValuePair(&builder, BEACON_KEY_START_SEQUENCE_NUMBER, action.startSequenceNo)
 	b.addKeyValuePair(&builder, BEACON_KEY_TIME_0, timestamp.Sub(b.sessionStartTime).Milliseconds())
 	b.addKeyValuePair(&builder, BEACON_KEY_END_SEQUENCE_NUMBER, action.endSequenceNo)
-	b.addKeyValuePair(&builder, BEACON_KEY_TIME_1, action.endTime.Sub(action.startTime).Milliseconds())
+	b.addKeyValuePair(&builder, BEACON_KEY_TIME_1, event.EndTime.Sub(event.StartTime).Milliseconds())
 
 	b.addActionData(timestamp, &builder)
 
@@ -231,6 +254,13 @@ func (b *Beacon) GetSessionNumber() int {
 	return 1
 }
 
+func (b *Beacon) getDeviceID() int64 {
+	if b.configuration.PrivacyConfiguration.IsDeviceIDSendingAllowed() {
+		return b.deviceID
+	}
+	return b.randomDeviceID
+}
+
 func (b *Beacon) GetVisitStoreVersion() int {
 	return b.configuration.GetServerConfiguration().VisitStoreVersion
 
@@ -238,7 +268,7 @@ func (b *Beacon) GetVisitStoreVersion() int {
 
 func (b *Beacon) EndSessionAt(timestamp time.Time) {
 
-	if !b.isDataCapturingEnabled() {
+	if !b.isDataCapturingEnabled() || !b.isEventTypeAllowed(SESSION_END) {
 		return
 	}
 
@@ -269,7 +299,7 @@ func (b *Beacon) isErrorCapturingEnabled() bool {
 func (b *Beacon) buildBasicEventDataWithoutName(builder *strings.Builder, eventType EventType) {
 
 	b.addKeyValuePair(builder, BEACON_KEY_EVENT_TYPE, eventType)
-	b.addKeyValuePair(builder, BEACON_KEY_THREAD_ID, 1)
+	b.addKeyValuePair(builder, BEACON_KEY_THREAD_ID, b.threadIDProvider.GetThreadID())
 
 }
 
@@ -327,7 +357,7 @@ func (b *Beacon) createImmutableBasicBeaconData() string {
 	b.addKeyValuePair(&builder, BEACON_KEY_PLATFORM_TYPE, protocol.PLATFORM_TYPE_OPENKIT)
 	b.addKeyValuePair(&builder, BEACON_KEY_AGENT_TECHNOLOGY_TYPE, b.configuration.HttpClientConfiguration.Technology)
 
-	b.addKeyValuePair(&builder, BEACON_KEY_VISITOR_ID, b.deviceID)
+	b.addKeyValuePair(&builder, BEACON_KEY_VISITOR_ID, b.getDeviceID())
 	b.addKeyValuePair(&builder, BEACON_KEY_SESSION_NUMBER, b.GetSessionNumber())
 	b.addKeyValuePair(&builder, BEACON_KEY_CLIENT_IP_ADDRESS, b.clientIPAddress)
 
@@ -337,8 +367,8 @@ func (b *Beacon) createImmutableBasicBeaconData() string {
 
 	privacyConfig := b.configuration.PrivacyConfiguration
 
-	b.addKeyValuePair(&builder, BEACON_KEY_DATA_COLLECTION_LEVEL, privacyConfig.DataCollectionLevel)
-	b.addKeyValuePair(&builder, BEACON_KEY_CRASH_REPORTING_LEVEL, privacyConfig.CrashReportingLevel)
+	b.addKeyValuePair(&builder, BEACON_KEY_DATA_COLLECTION_LEVEL, privacyConfig.GetDataCollectionLevel())
+	b.addKeyValuePair(&builder, BEACON_KEY_CRASH_REPORTING_LEVEL, privacyConfig.GetCrashReportingLevel())
 
 	return builder.String()
 }
@@ -347,7 +377,7 @@ func (b *Beacon) createTimestampData() string {
 
 	var builder strings.Builder
 
-	b.addKeyValuePair(&builder, BEACON_KEY_TRANSMISSION_TIME, utils.TimeToMillis(time.Now()))
+	b.addKeyValuePair(&builder, BEACON_KEY_TRANSMISSION_TIME, utils.TimeToMillis(b.clock.Now()))
 	b.addKeyValuePair(&builder, BEACON_KEY_SESSION_START_TIME, utils.TimeToMillis(b.sessionStartTime))
 
 	return builder.String()
@@ -387,13 +417,52 @@ func (b *Beacon) addKeyValuePairIfNotNull(builder *strings.Builder, key string,
 
 func (b *Beacon) addKeyValuePairIfNotNegative(builder *strings.Builder, key string, value interface{}) {
 
+	if v, ok := toInt64(value); ok && v >= 0 {
+		b.addKeyValuePair(builder, key, v)
+	}
+
+}
+
+// valueTypeOf returns the event type a value is reported as, false for nil
+func valueTypeOf(value interface{}) (EventType, bool) {
+	if value == nil {
+		return 0, false
+	}
+	if _, ok := toInt64(value); ok {
+		return VALUE_INT, true
+	}
+	switch value.(type) {
+	case float64, float32:
+		return VALUE_DOUBLE, true
+	}
+	return VALUE_STRING, true
+}
+
+// toInt64 converts any integer type to int64, large unsigned values are not representable and rejected
+func toInt64(value interface{}) (int64, bool) {
 	switch v := value.(type) {
 	case int:
-		if v > 0 {
-			b.addKeyValuePair(builder, key, value)
-		}
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
 	}
-
+	return 0, false
 }
 
 func (b *Beacon) IsEmpty() bool {
@@ -419,7 +488,7 @@ func (b *Beacon) send(ctx *BeaconSendingContext) protocol.StatusResponse {
 
 	b.cache.PrepareDataForSending(b.key)
 	for b.cache.HasDataForSending(b.key) {
-		prefix := b.appendMutableBeaconData(b.immutableBasicBeaconData)
+		prefix := b.appendMutableBeaconData(b.createImmutableBasicBeaconData())
 
 		chunk := b.cache.GetNextBeaconChunk(b.key, prefix, b.configuration.ServerConfiguration.BeaconSizeInBytes-1024, BEACON_DATA_DELIMITER)
 
@@ -427,8 +496,8 @@ func (b *Beacon) send(ctx *BeaconSendingContext) protocol.StatusResponse {
 			return statusResponse
 		}
 
-		statusResponse := httpClient.sendBeaconRequest(b.clientIPAddress, []byte(chunk), ctx)
-		if statusResponse.ResponseCode > 400 {
+		statusResponse = httpClient.sendBeaconRequest(b.clientIPAddress, []byte(chunk), ctx)
+		if statusResponse.IsErroneousResponse() {
 			b.cache.ResetChunkedData(b.key)
 			break
 		} else {
@@ -460,7 +529,7 @@ func (b *Beacon) setServerConfigurationUpdateCallback(callback ServerConfigurati
 }
 
 func (b *Beacon) startSession() {
-	if !b.isDataCapturingEnabled() {
+	if !b.isDataCapturingEnabled() || !b.isEventTypeAllowed(SESSION_START) {
 		return
 	}
 
@@ -477,95 +546,162 @@ func (b *Beacon) startSession() {
 }
 
 func (b *Beacon) reportEvent(parentActionID int, eventName string, timestamp time.Time) {
-	if !b.isDataCapturingEnabled() {
+	if !b.isDataCapturingEnabled() || !b.isEventTypeAllowed(NAMED_EVENT) {
+		return
+	}
+
+	event := b.applyBeforeSend(&interfaces.Event{
+		Type:           NAMED_EVENT,
+		Name:           eventName,
+		ParentActionID: parentActionID,
+		StartTime:      timestamp,
+	})
+	if event == nil {
 		return
 	}
 
 	var builder strings.Builder
-	b.buildEvent(&builder, NAMED_EVENT, eventName, parentActionID, timestamp)
-	b.addEventData(timestamp, &builder)
+	b.buildEvent(&builder, NAMED_EVENT, b.redact(redaction.FIELD_EVENT_NAME, event.Name), event.ParentActionID, event.StartTime)
+	b.addEventData(event.StartTime, &builder)
 }
 func (b *Beacon) reportValue(parentActionID int, valueName string, value interface{}, timestamp time.Time) {
 	if !b.isDataCapturingEnabled() {
 		return
 	}
 
-	valueType := VALUE_STRING
-	switch value.(type) {
-	case string:
-		valueType = VALUE_STRING
-	case int, int64, int32, uint32, uint64:
-		valueType = VALUE_INT
-	case float64, float32:
-		valueType = VALUE_DOUBLE
+	valueType, ok := valueTypeOf(value)
+	if !ok || !b.isEventTypeAllowed(valueType) {
+		return
+	}
+
+	event := b.applyBeforeSend(&interfaces.Event{
+		Type:           valueType,
+		Name:           valueName,
+		ParentActionID: parentActionID,
+		StartTime:      timestamp,
+		Fields:         map[string]interface{}{interfaces.EVENT_FIELD_VALUE: value},
+	})
+	if event == nil {
+		return
+	}
+
+	// the hook may have replaced the value, the event type follows the value that is sent
+	value = event.Fields[interfaces.EVENT_FIELD_VALUE]
+	if valueType, ok = valueTypeOf(value); !ok {
+		b.log.WithFields(log.Fields{"valueName": event.Name}).Warning("BeforeSend removed the value, the event is dropped")
+		return
+	}
+	if valueType == VALUE_STRING {
+		value = b.redact(redaction.FIELD_VALUE, fmt.Sprintf("%v", value))
 	}
 
 	var builder strings.Builder
-	b.buildEvent(&builder, valueType, valueName, parentActionID, timestamp)
+	b.buildEvent(&builder, valueType, b.redact(redaction.FIELD_VALUE_NAME, event.Name), event.ParentActionID, event.StartTime)
 	b.addKeyValuePair(&builder, BEACON_KEY_VALUE, value)
-	b.addEventData(timestamp, &builder)
+	b.addEventData(event.StartTime, &builder)
 
 }
 
 func (b *Beacon) reportError(parentActionID int, errorName string, causeName string, causeDescription string, causeStackTrace string, timestamp time.Time) {
-	if !b.isDataCapturingEnabled() {
+	if !b.isDataCapturingEnabled() || !b.isEventTypeAllowed(EXCEPTION) {
+		return
+	}
+
+	event := b.applyBeforeSend(&interfaces.Event{
+		Type:           EXCEPTION,
+		Name:           errorName,
+		ParentActionID: parentActionID,
+		StartTime:      timestamp,
+		Fields: map[string]interface{}{
+			interfaces.EVENT_FIELD_CAUSE:      causeName,
+			interfaces.EVENT_FIELD_REASON:     causeDescription,
+			interfaces.EVENT_FIELD_STACKTRACE: causeStackTrace,
+		},
+	})
+	if event == nil {
 		return
 	}
 
 	var builder strings.Builder
 
-	b.buildBasicEventData(&builder, EXCEPTION, errorName)
+	b.buildBasicEventData(&builder, EXCEPTION, b.redact(redaction.FIELD_ERROR_NAME, event.Name))
 
-	b.addKeyValuePair(&builder, BEACON_KEY_PARENT_ACTION_ID, parentActionID)
+	b.addKeyValuePair(&builder, BEACON_KEY_PARENT_ACTION_ID, event.ParentActionID)
 	b.addKeyValuePair(&builder, BEACON_KEY_START_SEQUENCE_NUMBER, b.CreateSequenceNumber())
-	b.addKeyValuePair(&builder, BEACON_KEY_TIME_0, timestamp.Sub(b.sessionStartTime).Milliseconds())
-	b.addKeyValuePairIfNotNull(&builder, BEACON_KEY_ERROR_VALUE, causeName)
-	b.addKeyValuePairIfNotNull(&builder, BEACON_KEY_ERROR_REASON, causeDescription)
-	b.addKeyValuePairIfNotNull(&builder, BEACON_KEY_ERROR_STACKTRACE, causeStackTrace)
+	b.addKeyValuePair(&builder, BEACON_KEY_TIME_0, event.StartTime.Sub(b.sessionStartTime).Milliseconds())
+	b.addKeyValuePairIfNotNull(&builder, BEACON_KEY_ERROR_VALUE, b.redact(redaction.FIELD_ERROR_NAME, stringField(event, interfaces.EVENT_FIELD_CAUSE)))
+	b.addKeyValuePairIfNotNull(&builder, BEACON_KEY_ERROR_REASON, b.redact(redaction.FIELD_ERROR_REASON, stringField(event, interfaces.EVENT_FIELD_REASON)))
+	b.addKeyValuePairIfNotNull(&builder, BEACON_KEY_ERROR_STACKTRACE, b.redact(redaction.FIELD_STACKTRACE, stringField(event, interfaces.EVENT_FIELD_STACKTRACE)))
 	b.addKeyValuePair(&builder, BEACON_KEY_ERROR_TECHNOLOGY_TYPE, protocol.ERROR_TECHNOLOGY_TYPE)
 
-	b.addEventData(timestamp, &builder)
+	b.addEventData(event.StartTime, &builder)
 }
 func (b *Beacon) addWebRequest(parentActionID int, tracer interfaces.WebRequestTracer) {
 
-	if !b.isErrorCapturingEnabled() {
+	if !b.isErrorCapturingEnabled() || !b.isEventTypeAllowed(WEB_REQUEST) {
+		return
+	}
+
+	webRequest := tracer.(*WebRequestTracer)
+	event := b.applyBeforeSend(&interfaces.Event{
+		Type:           WEB_REQUEST,
+		Name:           webRequest.url,
+		ParentActionID: parentActionID,
+		StartTime:      webRequest.startTime,
+		EndTime:        webRequest.endTime,
+		Fields: map[string]interface{}{
+			interfaces.EVENT_FIELD_BYTES_SENT:     webRequest.bytesSent,
+			interfaces.EVENT_FIELD_BYTES_RECEIVED: webRequest.bytesReceived,
+			interfaces.EVENT_FIELD_RESPONSE_CODE:  webRequest.responseCode,
+		},
+	})
+	if event == nil {
 		return
 	}
 
 	var builder strings.Builder
 
-	b.buildBasicEventData(&builder, WEB_REQUEST, tracer.(*WebRequestTracer).url)
+	b.buildBasicEventData(&builder, WEB_REQUEST, b.redact(redaction.FIELD_URL, event.Name))
 
-	b.addKeyValuePair(&builder, BEACON_KEY_PARENT_ACTION_ID, parentActionID)
-	b.addKeyValuePair(&builder, BEACON_KEY_START_SEQUENCE_NUMBER, tracer.(*WebRequestTracer).startSequenceNo)
-	b.addKeyValuePair(&builder, BEACON_KEY_TIME_0, tracer.(*WebRequestTracer).startTime.Sub(b.sessionStartTime).Milliseconds())
-	b.addKeyValuePair(&builder, BEACON_KEY_END_SEQUENCE_NUMBER, tracer.(*WebRequestTracer).endSequenceNo)
-	b.addKeyValuePair(&builder, BEACON_KEY_TIME_1, tracer.(*WebRequestTracer).endTime.Sub(tracer.(*WebRequestTracer).startTime).Milliseconds())
+	b.addKeyValuePair(&builder, BEACON_KEY_PARENT_ACTION_ID, event.ParentActionID)
+	b.addKeyValuePair(&builder, BEACON_KEY_START_SEQUENCE_NUMBER, webRequest.startSequenceNo)
+	b.addKeyValuePair(&builder, BEACON_KEY_TIME_0, event.StartTime.Sub(b.sessionStartTime).Milliseconds())
+	b.addKeyValuePair(&builder, BEACON_KEY_END_SEQUENCE_NUMBER, webRequest.endSequenceNo)
+	b.addKeyValuePair(&builder, BEACON_KEY_TIME_1, event.EndTime.Sub(event.StartTime).Milliseconds())
 
-	b.addKeyValuePairIfNotNegative(&builder, BEACON_KEY_WEBREQUEST_BYTES_SENT, tracer.(*WebRequestTracer).bytesSent)
-	b.addKeyValuePairIfNotNegative(&builder, BEACON_KEY_WEBREQUEST_BYTES_RECEIVED, tracer.(*WebRequestTracer).bytesReceived)
-	b.addKeyValuePairIfNotNegative(&builder, BEACON_KEY_WEBREQUEST_RESPONSECODE, tracer.(*WebRequestTracer).responseCode)
+	b.addKeyValuePairIfNotNegative(&builder, BEACON_KEY_WEBREQUEST_BYTES_SENT, event.Fields[interfaces.EVENT_FIELD_BYTES_SENT])
+	b.addKeyValuePairIfNotNegative(&builder, BEACON_KEY_WEBREQUEST_BYTES_RECEIVED, event.Fields[interfaces.EVENT_FIELD_BYTES_RECEIVED])
+	b.addKeyValuePairIfNotNegative(&builder, BEACON_KEY_WEBREQUEST_RESPONSECODE, event.Fields[interfaces.EVENT_FIELD_RESPONSE_CODE])
 
-	b.addEventData(tracer.(*WebRequestTracer).startTime, &builder)
+	b.addEventData(event.StartTime, &builder)
 }
 
 func (b *Beacon) identifyUser(userTag string, timestamp time.Time) {
-	if !b.isDataCapturingEnabled() {
+	if !b.isDataCapturingEnabled() || !b.isEventTypeAllowed(IDENTIFY_USER) {
 		return
 	}
+	event := b.applyBeforeSend(&interfaces.Event{
+		Type:      IDENTIFY_USER,
+		Name:      userTag,
+		StartTime: timestamp,
+	})
+	if event == nil {
+		return
+	}
+
 	var builder strings.Builder
 
-	if userTag != "" {
-		b.buildBasicEventData(&builder, IDENTIFY_USER, userTag)
+	if event.Name != "" {
+		b.buildBasicEventData(&builder, IDENTIFY_USER, b.redact(redaction.FIELD_USER_TAG, event.Name))
 	} else {
 		b.buildBasicEventDataWithoutName(&builder, IDENTIFY_USER)
 	}
 
 	b.addKeyValuePair(&builder, BEACON_KEY_PARENT_ACTION_ID, 0)
 	b.addKeyValuePair(&builder, BEACON_KEY_START_SEQUENCE_NUMBER, b.CreateSequenceNumber())
-	b.addKeyValuePair(&builder, BEACON_KEY_TIME_0, timestamp.Sub(b.sessionStartTime).Milliseconds())
+	b.addKeyValuePair(&builder, BEACON_KEY_TIME_0, event.StartTime.Sub(b.sessionStartTime).Milliseconds())
 
-	b.addEventData(timestamp, &builder)
+	b.addEventData(event.StartTime, &builder)
 }
 
 func (b *Beacon) initializeServerConfiguration(c *configuration.ServerConfiguration) {
@@ -577,22 +713,60 @@ func (b *Beacon) enableCapture() {
 
 func (b *Beacon) reportCrash(name string, reason string, stacktrace string, timestamp time.Time) {
 
-	if !b.isDataCapturingEnabled() {
+	if !b.isDataCapturingEnabled() || !b.isEventTypeAllowed(CRASH) {
+		return
+	}
+
+	event := b.applyBeforeSend(&interfaces.Event{
+		Type:      CRASH,
+		Name:      name,
+		StartTime: timestamp,
+		Fields: map[string]interface{}{
+			interfaces.EVENT_FIELD_REASON:     reason,
+			interfaces.EVENT_FIELD_STACKTRACE: stacktrace,
+		},
+	})
+	if event == nil {
 		return
 	}
 
 	var builder strings.Builder
 
-	b.buildBasicEventData(&builder, CRASH, name)
+	b.buildBasicEventData(&builder, CRASH, b.redact(redaction.FIELD_ERROR_NAME, event.Name))
 
 	b.addKeyValuePair(&builder, BEACON_KEY_PARENT_ACTION_ID, 0)
 	b.addKeyValuePair(&builder, BEACON_KEY_START_SEQUENCE_NUMBER, b.CreateSequenceNumber())
-	b.addKeyValuePair(&builder, BEACON_KEY_TIME_0, timestamp.Sub(b.sessionStartTime).Milliseconds())
-	b.addKeyValuePairIfNotNull(&builder, BEACON_KEY_ERROR_REASON, reason)
-	b.addKeyValuePairIfNotNull(&builder, BEACON_KEY_ERROR_STACKTRACE, stacktrace)
+	b.addKeyValuePair(&builder, BEACON_KEY_TIME_0, event.StartTime.Sub(b.sessionStartTime).Milliseconds())
+	b.addKeyValuePairIfNotNull(&builder, BEACON_KEY_ERROR_REASON, b.redact(redaction.FIELD_ERROR_REASON, stringField(event, interfaces.EVENT_FIELD_REASON)))
+	b.addKeyValuePairIfNotNull(&builder, BEACON_KEY_ERROR_STACKTRACE, b.redact(redaction.FIELD_STACKTRACE, stringField(event, interfaces.EVENT_FIELD_STACKTRACE)))
 	b.addKeyValuePair(&builder, BEACON_KEY_ERROR_TECHNOLOGY_TYPE, protocol.ERROR_TECHNOLOGY_TYPE)
 
-	b.addEventData(timestamp, &builder)
+	b.addEventData(event.StartTime, &builder)
+}
+
+// applyBeforeSend passes event to the BeforeSend hook, a nil result drops the event
+func (b *Beacon) applyBeforeSend(event *interfaces.Event) *interfaces.Event {
+	if b.beforeSend == nil {
+		return event
+	}
+	return b.beforeSend(event)
+}
+
+// stringField returns the field of event as a string, "" if it is not set
+func stringField(event *interfaces.Event, key string) string {
+	value, ok := event.Fields[key]
+	if !ok || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// redact applies the redaction rules to data before it is added to the cache
+func (b *Beacon) redact(field redaction.Field, value string) string {
+	return b.redactor.Redact(field, value)
 }
 
 func truncate(name string) string {
@@ -602,3 +776,55 @@ func truncate(name string) string {
 	}
 	return name
 }
+
+// isEventTypeAllowed tells if the privacy configuration allows data of eventType
+func (b *Beacon) isEventTypeAllowed(eventType EventType) bool {
+	privacyConfig := b.configuration.PrivacyConfiguration
+	switch eventType {
+	case ACTION:
+		return privacyConfig.IsActionReportingAllowed()
+	case VALUE_STRING, VALUE_INT, VALUE_DOUBLE:
+		return privacyConfig.IsValueReportingAllowed()
+	case NAMED_EVENT:
+		return privacyConfig.IsEventReportingAllowed()
+	case SESSION_START, SESSION_END:
+		return privacyConfig.IsSessionReportingAllowed()
+	case WEB_REQUEST:
+		return privacyConfig.IsWebRequestTracingAllowed()
+	case ERROR, EXCEPTION:
+		return privacyConfig.IsErrorReportingAllowed()
+	case CRASH:
+		return privacyConfig.IsCrashReportingAllowed()
+	case IDENTIFY_USER:
+		return privacyConfig.IsUserIdentificationAllowed()
+	}
+	return false
+}
+
+// purgeDisallowedData removes cached data that the current privacy configuration does not allow anymore
+func (b *Beacon) purgeDisallowedData() {
+	removed := b.cache.RemoveRecordsIf(b.key, func(data string) bool {
+		return !b.isEventTypeAllowed(eventTypeOf(data))
+	})
+	b.log.WithFields(log.Fields{"key": b.key.String(), "removed": removed}).Debug("Beacon.purgeDisallowedData()")
+
+	// the levels persisted with the data follow the current ones
+	caching.SetMetadata(b.cache, b.key, b.createMetadata())
+}
+
+// eventTypeOf reads the event type from cached data, every record starts with it
+func eventTypeOf(data string) EventType {
+	prefix := BEACON_KEY_EVENT_TYPE + "="
+	if !strings.HasPrefix(data, prefix) {
+		return 0
+	}
+	data = data[len(prefix):]
+	if end := strings.IndexRune(data, BEACON_DATA_DELIMITER); end >= 0 {
+		data = data[:end]
+	}
+	eventType, err := strconv.Atoi(data)
+	if err != nil {
+		return 0
+	}
+	return EventType(eventType)
+}
@@ -2,7 +2,8 @@ package core
 
 import (
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
-	log "github.com/sirupsen/logrus"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
 	"time"
 )
 
@@ -12,26 +13,28 @@ const (
 )
 
 type BeaconSender struct {
-	log     *log.Logger
+	log     log.Logger
 	context *BeaconSendingContext
 }
 
-func NewBeaconSender(log *log.Logger, httpClientConfig *configuration.HttpClientConfiguration) *BeaconSender {
+func NewBeaconSender(log log.Logger, httpClientConfig *configuration.HttpClientConfiguration, clock providers.Clock) *BeaconSender {
 
 	return &BeaconSender{
 		log:     log,
-		context: NewBeaconSendingContext(log, httpClientConfig),
+		context: NewBeaconSendingContext(log, httpClientConfig, clock),
 	}
 }
 
 // BeaconSenderRoutine contains the goroutine that runs until a shutdown is requested
-func BeaconSenderRoutine(log *log.Logger, ctx *BeaconSendingContext) {
+func BeaconSenderRoutine(log log.Logger, ctx *BeaconSendingContext) {
 
 	go func() {
 		log.Debug("BeaconSenderRoutine.start()")
+		defer close(ctx.done)
 		for !ctx.IsInTerminalState() {
 			ctx.executeCurrentState()
 		}
+		ctx.cancelFlushRequests()
 		log.Debug("BeaconSenderRoutine.stop()")
 	}()
 }
@@ -56,6 +59,24 @@ func (s *BeaconSender) Shutdown() {
 	s.context.requestShutDown()
 }
 
+// Flush wakes up the sender and returns a channel that receives the result once all cached data was sent
+func (s *BeaconSender) Flush() <-chan error {
+	return s.context.requestFlush()
+}
+
+func (s *BeaconSender) isCaptureOn() bool {
+	return s.context.isCaptureOn()
+}
+
+// Done is closed once the sender reached its terminal state
+func (s *BeaconSender) Done() <-chan struct{} {
+	return s.context.done
+}
+
+func (s *BeaconSender) getSessionCount() int {
+	return s.context.getSessionCount()
+}
+
 func (s *BeaconSender) GetLastServerConfiguration() *configuration.ServerConfiguration {
 	return s.context.GetLastServerConfiguration()
 }
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBeforeSend(t *testing.T) {
+	var mutex sync.Mutex
+	var seen []interfaces.Event
+
+	builder := NewOpenKitBuilder("https://localhost:9999/mbeacon", "app", 42).
+		WithLogLevel(log.WarnLevel).
+		WithBeforeSend(func(event *interfaces.Event) *interfaces.Event {
+			mutex.Lock()
+			seen = append(seen, *event)
+			mutex.Unlock()
+
+			switch {
+			case event.Type == interfaces.WEB_REQUEST && strings.HasSuffix(event.Name, "/health"):
+				return nil
+			case event.Type == interfaces.ACTION:
+				event.Name = "Renamed"
+			case event.Type == interfaces.VALUE_INT:
+				event.Fields[interfaces.EVENT_FIELD_VALUE] = 7
+			}
+			return event
+		})
+	ok := NewOpenKit(builder.(*OpenKitBuilder)).(*OpenKit)
+
+	proxy := ok.CreateSession("").(*SessionProxy)
+	action := proxy.EnterAction("Original")
+	action.ReportValue("count", 1)
+	action.ReportError("error", "cause", "description", "stacktrace")
+	action.TraceWebRequest("https://example.com/health").Stop(200)
+	action.TraceWebRequest("https://example.com/orders").Stop(201)
+	action.LeaveAction()
+
+	assert.ElementsMatch(t, []EventType{SESSION_START, VALUE_INT, EXCEPTION, WEB_REQUEST, ACTION}, cachedEventTypes(proxy))
+
+	beacon := proxy.currentSession.beacon
+	beacon.cache.PrepareDataForSending(beacon.key)
+	data := beacon.cache.GetNextBeaconChunk(beacon.key, "", 100000, BEACON_DATA_DELIMITER)
+	assert.Contains(t, data, "na=Renamed")
+	assert.Contains(t, data, "vl=7")
+	assert.Contains(t, data, "orders")
+	assert.NotContains(t, data, "health")
+
+	var errorEvent interfaces.Event
+	for _, event := range seen {
+		if event.Type == interfaces.EXCEPTION {
+			errorEvent = event
+		}
+	}
+	assert.Equal(t, "error", errorEvent.Name)
+	assert.Equal(t, int(action.(*Action).id), errorEvent.ParentActionID)
+	assert.Equal(t, "description", errorEvent.Fields[interfaces.EVENT_FIELD_REASON])
+	assert.Equal(t, 5, len(seen))
+}
+
+func TestBeforeSendChangesValueTypes(t *testing.T) {
+	builder := NewOpenKitBuilder("https://localhost:9999/mbeacon", "app", 42).
+		WithLogLevel(log.WarnLevel).
+		WithBeforeSend(func(event *interfaces.Event) *interfaces.Event {
+			switch {
+			case event.Name == "to string":
+				event.Fields[interfaces.EVENT_FIELD_VALUE] = "seven"
+			case event.Name == "to double":
+				event.Fields[interfaces.EVENT_FIELD_VALUE] = 7.5
+			case event.Name == "removed":
+				delete(event.Fields, interfaces.EVENT_FIELD_VALUE)
+			case event.Type == interfaces.WEB_REQUEST:
+				event.Fields[interfaces.EVENT_FIELD_BYTES_SENT] = int64(0)
+				event.Fields[interfaces.EVENT_FIELD_BYTES_RECEIVED] = uint32(12)
+				event.Fields[interfaces.EVENT_FIELD_RESPONSE_CODE] = int64(204)
+			}
+			return event
+		})
+	ok := NewOpenKit(builder.(*OpenKitBuilder)).(*OpenKit)
+
+	proxy := ok.CreateSession("").(*SessionProxy)
+	action := proxy.EnterAction("action")
+	action.ReportValue("to string", 7)
+	action.ReportValue("to double", 7)
+	action.ReportValue("removed", 7)
+	action.TraceWebRequest("https://example.com/orders").Stop(200)
+	action.LeaveAction()
+
+	assert.ElementsMatch(t, []EventType{SESSION_START, VALUE_STRING, VALUE_DOUBLE, WEB_REQUEST, ACTION}, cachedEventTypes(proxy))
+
+	beacon := proxy.currentSession.beacon
+	beacon.cache.PrepareDataForSending(beacon.key)
+	data := beacon.cache.GetNextBeaconChunk(beacon.key, "", 100000, BEACON_DATA_DELIMITER)
+	assert.Contains(t, data, "vl=seven")
+	assert.Contains(t, data, "vl=7.5")
+	assert.NotContains(t, data, "removed")
+	assert.Contains(t, data, "&bs=0&br=12&rc=204")
+}
@@ -4,8 +4,9 @@ import (
 	"crypto/tls"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
-	log "github.com/sirupsen/logrus"
 	"net/http"
 	"os"
 	"testing"
@@ -13,14 +14,14 @@ import (
 )
 
 var beacon *Beacon
-var logger *log.Logger
+var logger log.Logger
 var httpClient HttpClient
 var ctx *BeaconSendingContext
 
 func TestMain(m *testing.M) {
 
-	logger = log.New()
-	logger.SetLevel(log.DebugLevel)
+	logger = log.NewLogrusLogger(nil)
+	logger.(log.LevelSetter).SetLevel(log.DebugLevel)
 
 	ok := NewOpenKitBuilder(
 		"https://localhost:9999/mbeacon/e/eaa50379",
@@ -36,14 +37,11 @@ func TestMain(m *testing.M) {
 		ApplicationID: "98972aef-02ac-4ecb-be1e-a6698af2de60",
 		Transport:     &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
 	}
-	ctx = NewBeaconSendingContext(logger, httpClientConfig)
+	ctx = NewBeaconSendingContext(logger, httpClientConfig, providers.NewDefaultClock())
 	httpClient = NewHttpClient(logger, httpClientConfig)
 
 	o := &configuration.OpenKitConfiguration{}
-	p := &configuration.PrivacyConfiguration{
-		DataCollectionLevel: configuration.DATA_USER_BEHAVIOR,
-		CrashReportingLevel: configuration.CRASH_OPT_IN_CRASHES,
-	}
+	p := configuration.NewPrivacyConfiguration(configuration.DATA_USER_BEHAVIOR, configuration.CRASH_OPT_IN_CRASHES)
 	s := configuration.DefaultServerConfiguration()
 	s.Capture = true
 	s.TrafficControlPercentage = 100
@@ -51,12 +49,12 @@ func TestMain(m *testing.M) {
 	c := configuration.NewBeaconConfiguration(o, p, 1)
 	c.ServerConfiguration = s
 
-	sessionWatchdog := NewSessionWatchdog(logger, NewSessionWatchdogContext())
+	sessionWatchdog := NewSessionWatchdog(logger, NewSessionWatchdogContext(providers.NewDefaultClock()))
 
 	beacon = NewBeacon(logger,
 		caching.NewBeaconCache(logger),
-		providers.NewSessionIDProvider(),
-		NewSessionProxy(logger, ok.(*OpenKit), ok.(*OpenKit).beaconSender, sessionWatchdog, ok.(*OpenKit), "", time.Now()),
+		providers.NewSessionIDProvider(providers.NewDefaultRandomNumberGenerator()),
+		NewSessionProxy(logger, ok.(*OpenKit), ok.(*OpenKit).beaconSender, sessionWatchdog, ok.(*OpenKit), interfaces.SessionOptions{Timestamp: time.Now()}),
 		c,
 		time.Now(),
 		1,
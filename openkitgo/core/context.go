@@ -2,8 +2,9 @@ package core
 
 import (
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/protocol"
-	log "github.com/sirupsen/logrus"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,31 +15,42 @@ const (
 )
 
 type BeaconSendingContext struct {
-	log                     *log.Logger
+	log                     log.Logger
 	mutex                   sync.RWMutex
 	serverConfiguration     *configuration.ServerConfiguration
 	lastResponseAttributes  protocol.ResponseAttributes
 	httpClientConfiguration *configuration.HttpClientConfiguration
 	sessions                []*Session
+	clock                   providers.Clock
 
-	shutdown int32 // atomic
-	initWg   *sync.WaitGroup
+	shutdown     int32 // atomic
+	shutdownCh   chan struct{}
+	shutdownOnce sync.Once
+	done         chan struct{}
+	flushCh      chan struct{}
+	flushMutex   sync.Mutex
+	flushWaiters []chan error
+	initWg       *sync.WaitGroup
 
 	currentState BeaconState
 	nextState    BeaconState
 
 	lastOpenSessionSent time.Time
 	lastStatusCheck     time.Time
-	initOk              bool
+	initOk              bool // guarded by mutex
 }
 
-func NewBeaconSendingContext(log *log.Logger,
-	httpClientConfiguration *configuration.HttpClientConfiguration) *BeaconSendingContext {
+func NewBeaconSendingContext(log log.Logger,
+	httpClientConfiguration *configuration.HttpClientConfiguration, clock providers.Clock) *BeaconSendingContext {
 	b := &BeaconSendingContext{
 		log:                     log,
 		serverConfiguration:     configuration.DefaultServerConfiguration(),
 		lastResponseAttributes:  protocol.UndefinedResponseAttributes(),
 		httpClientConfiguration: httpClientConfiguration,
+		clock:                   clock,
+		shutdownCh:              make(chan struct{}),
+		done:                    make(chan struct{}),
+		flushCh:                 make(chan struct{}, 1),
 		initWg:                  &sync.WaitGroup{},
 		currentState:            NewStateInit(),
 	}
@@ -60,7 +72,85 @@ func (c *BeaconSendingContext) executeCurrentState() {
 }
 
 func (c *BeaconSendingContext) getCurrentTimestamp() time.Time {
-	return time.Now()
+	return c.clock.Now()
+}
+
+// sleep blocks for the given duration, or until a shutdown is requested
+func (c *BeaconSendingContext) sleep(duration time.Duration) {
+	timer := c.clock.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+	case <-c.shutdownCh:
+	}
+}
+
+// sleepRejectingFlushes blocks like sleep, flush requests that arrive in the meantime are answered with err right away
+func (c *BeaconSendingContext) sleepRejectingFlushes(duration time.Duration, err error) {
+	timer := c.clock.NewTimer(duration)
+	defer timer.Stop()
+	for {
+		c.rejectFlushRequests(err)
+		select {
+		case <-timer.C():
+			return
+		case <-c.shutdownCh:
+			return
+		case <-c.flushCh:
+		}
+	}
+}
+
+// waitForWakeup blocks for the given duration, or until a shutdown or a flush is requested,
+// and returns the flush requests that have to be answered
+func (c *BeaconSendingContext) waitForWakeup(duration time.Duration) []chan error {
+	timer := c.clock.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+	case <-c.shutdownCh:
+	case <-c.flushCh:
+	}
+
+	c.flushMutex.Lock()
+	defer c.flushMutex.Unlock()
+	waiters := c.flushWaiters
+	c.flushWaiters = nil
+	return waiters
+}
+
+// requestFlush wakes up the sender, the returned channel receives the result once all data was sent
+func (c *BeaconSendingContext) requestFlush() <-chan error {
+	result := make(chan error, 1)
+
+	c.flushMutex.Lock()
+	c.flushWaiters = append(c.flushWaiters, result)
+	c.flushMutex.Unlock()
+
+	select {
+	case c.flushCh <- struct{}{}:
+	default:
+	}
+	return result
+}
+
+func (c *BeaconSendingContext) completeFlush(waiters []chan error, err error) {
+	for _, waiter := range waiters {
+		waiter <- err
+	}
+}
+
+// cancelFlushRequests answers all flush requests that are still pending when the sender stops
+func (c *BeaconSendingContext) cancelFlushRequests() {
+	c.rejectFlushRequests(ErrShutDown)
+}
+
+// rejectFlushRequests answers all pending flush requests with err
+func (c *BeaconSendingContext) rejectFlushRequests(err error) {
+	c.flushMutex.Lock()
+	defer c.flushMutex.Unlock()
+	c.completeFlush(c.flushWaiters, err)
+	c.flushWaiters = nil
 }
 
 func (c *BeaconSendingContext) getHttpClient() HttpClient {
@@ -120,19 +210,29 @@ func (c *BeaconSendingContext) updateFrom(statusResponse protocol.StatusResponse
 
 func (c *BeaconSendingContext) requestShutDown() {
 	atomic.StoreInt32(&c.shutdown, 1)
+	c.shutdownOnce.Do(func() {
+		close(c.shutdownCh)
+	})
+}
+
+// initCompleted records the result of the initial status request and releases the goroutines waiting for it
+func (c *BeaconSendingContext) initCompleted(ok bool) {
+	c.mutex.Lock()
+	c.initOk = ok
+	c.mutex.Unlock()
+	c.initWg.Done()
 }
 
 func (c *BeaconSendingContext) WaitForInitTimeout(timeout time.Duration) bool {
 	if waitTimeout(c.initWg, timeout) {
 		c.log.WithFields(log.Fields{"timeout": timeout}).Error("timed out waiting for init")
-		return c.initOk
 	}
-	return c.initOk
+	return c.IsInitialized()
 }
 
 func (c *BeaconSendingContext) WaitForInit() bool {
 	c.initWg.Wait()
-	return c.initOk
+	return c.IsInitialized()
 }
 
 func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
@@ -150,6 +250,8 @@ func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
 }
 
 func (c *BeaconSendingContext) IsInitialized() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.initOk
 }
 
@@ -191,7 +293,7 @@ func (c *BeaconSendingContext) clearAllSessionData() {
 
 func (c *BeaconSendingContext) getAllNotConfiguredSessions() []*Session {
 	c.mutex.RLock()
-	c.mutex.RUnlock()
+	defer c.mutex.RUnlock()
 	var filtered []*Session
 
 	for _, session := range c.sessions {
@@ -206,7 +308,7 @@ func (c *BeaconSendingContext) getAllNotConfiguredSessions() []*Session {
 
 func (c *BeaconSendingContext) getAllOpenAndConfiguredSessions() []*Session {
 	c.mutex.RLock()
-	c.mutex.RUnlock()
+	defer c.mutex.RUnlock()
 	var filtered []*Session
 
 	for _, session := range c.sessions {
@@ -219,7 +321,7 @@ func (c *BeaconSendingContext) getAllOpenAndConfiguredSessions() []*Session {
 
 func (c *BeaconSendingContext) getAllFinishedAndConfiguredSessions() []*Session {
 	c.mutex.RLock()
-	c.mutex.RUnlock()
+	defer c.mutex.RUnlock()
 	var filtered []*Session
 
 	for _, session := range c.sessions {
@@ -230,19 +332,25 @@ func (c *BeaconSendingContext) getAllFinishedAndConfiguredSessions() []*Session
 	return filtered
 }
 
+func (c *BeaconSendingContext) getSessionCount() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.sessions)
+}
+
 func (c *BeaconSendingContext) GetCurrentServerId() int {
 	return c.httpClientConfiguration.ServerID
 }
 
 func (c *BeaconSendingContext) AddSession(session *Session) {
 	c.mutex.Lock()
-	c.mutex.Unlock()
+	defer c.mutex.Unlock()
 	c.sessions = append(c.sessions, session)
 }
 
 func (c *BeaconSendingContext) RemoveSession(session *Session) {
 	c.mutex.Lock()
-	c.mutex.Unlock()
+	defer c.mutex.Unlock()
 	var keep []*Session
 
 	for _, s := range c.sessions {
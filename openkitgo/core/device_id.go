@@ -0,0 +1,20 @@
+package core
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// DeviceIDFromString converts a string device ID like the Java and .NET OpenKits do.
+// Numbers are used as they are, other strings are hashed with 64 bit FNV-1a over their UTF-8 bytes.
+func DeviceIDFromString(deviceID string) int64 {
+	deviceID = strings.TrimSpace(deviceID)
+	if id, err := strconv.ParseInt(deviceID, 10, 64); err == nil {
+		return id
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(deviceID))
+	return int64(h.Sum64())
+}
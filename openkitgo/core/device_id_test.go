@@ -0,0 +1,43 @@
+package core
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDeviceIDFromString(t *testing.T) {
+	assert.Equal(t, int64(42), DeviceIDFromString("42"))
+	assert.Equal(t, int64(-42), DeviceIDFromString(" -42 "))
+	assert.Equal(t, int64(-0x509c23b379fe1374), DeviceIDFromString("a")) // 0xaf63dc4c8601ec8c
+	assert.Equal(t, int64(-0x340d631b7bdddcdb), DeviceIDFromString(""))  // FNV-1a offset basis
+	assert.Equal(t, DeviceIDFromString("someone@example.com"), DeviceIDFromString("someone@example.com"))
+	assert.NotEqual(t, DeviceIDFromString("user-1"), DeviceIDFromString("user-2"))
+}
+
+func TestNewOpenKitBuilderWithStringDeviceID(t *testing.T) {
+	b := NewOpenKitBuilderWithStringDeviceID("https://localhost:9999/mbeacon", "app", "someone@example.com").(*OpenKitBuilder)
+
+	assert.Equal(t, DeviceIDFromString("someone@example.com"), b.deviceID)
+	assert.Equal(t, "someone@example.com", b.origDeviceID)
+
+	b = NewOpenKitBuilderWithStringDeviceID("https://localhost:9999/mbeacon", "app", "1234").(*OpenKitBuilder)
+	assert.Equal(t, int64(1234), b.deviceID)
+}
+
+func TestDeviceIDSettingAcceptsStrings(t *testing.T) {
+	settings := map[string]string{
+		SETTING_ENDPOINT_URL:   "https://localhost:9999/mbeacon",
+		SETTING_APPLICATION_ID: "app",
+		SETTING_DEVICE_ID:      "someone@example.com",
+	}
+	b, err := NewOpenKitBuilderFromSettings(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, DeviceIDFromString("someone@example.com"), b.deviceID)
+	assert.Equal(t, "someone@example.com", b.origDeviceID)
+
+	settings[SETTING_DEVICE_ID] = "1234"
+	b, err = NewOpenKitBuilderFromSettings(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1234), b.deviceID)
+	assert.Equal(t, "1234", b.origDeviceID)
+}
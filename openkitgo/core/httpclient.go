@@ -3,14 +3,18 @@ package core
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/protocol"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/utils"
-	log "github.com/sirupsen/logrus"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type RequestType int
@@ -32,33 +36,55 @@ const (
 
 	QUERY_RESERVED_CHARACTERS = "_"
 
-	MAX_SEND_RETRIES = 3
-	RETRY_SLEEP_TIME = 200
-	CONNECT_TIMEOUT  = 5000
-	READ_TIMEOUT     = 30000
+	MAX_SEND_RETRIES     = 3
+	RETRY_SLEEP_TIME     = 200 * time.Millisecond
+	MAX_RETRY_SLEEP_TIME = 10 * time.Second
+	RETRY_JITTER         = 0.2
+	CONNECT_TIMEOUT      = 5 * time.Second
+	READ_TIMEOUT         = 30 * time.Second
+	REQUEST_TIMEOUT      = 60 * time.Second
 )
 
 type HttpClient struct {
 	monitorURL    string
 	newSessionURL string
 	serverID      int
-	log           *log.Logger
+	log           log.Logger
 	parser        protocol.ResponseParser
 
-	transport *http.Transport
+	client         *http.Client
+	retryPolicy    configuration.RetryPolicy
+	random         providers.RandomNumberGenerator
+	requestTimeout time.Duration
 
 	requestTypes []string
 }
 
-func NewHttpClient(log *log.Logger, config *configuration.HttpClientConfiguration) HttpClient {
+func NewHttpClient(log log.Logger, config *configuration.HttpClientConfiguration) HttpClient {
+	client := config.Client
+	if client == nil {
+		client = &http.Client{}
+		if config.Transport != nil {
+			client.Transport = config.Transport
+		}
+	}
+
+	random := config.RandomNumberGenerator
+	if random == nil {
+		random = providers.NewDefaultRandomNumberGenerator()
+	}
+
 	return HttpClient{
-		monitorURL:    buildMonitorURL(config.BaseURL, config.ApplicationID, config.ServerID, config.Technology),
-		newSessionURL: buildNewSessionURL(config.BaseURL, config.ApplicationID, config.ServerID, config.Technology),
-		serverID:      config.ServerID,
-		log:           log,
-		parser:        protocol.NewResponseParser(log),
-		transport:     config.Transport,
-		requestTypes:  []string{"Status", "Beacon", "NewSession"},
+		monitorURL:     buildMonitorURL(config.BaseURL, config.ApplicationID, config.ServerID, config.Technology),
+		newSessionURL:  buildNewSessionURL(config.BaseURL, config.ApplicationID, config.ServerID, config.Technology),
+		serverID:       config.ServerID,
+		log:            log,
+		parser:         protocol.NewResponseParser(log),
+		client:         client,
+		retryPolicy:    config.RetryPolicy,
+		random:         random,
+		requestTimeout: config.RequestTimeout,
+		requestTypes:   []string{"Status", "Beacon", "NewSession"},
 	}
 }
 
@@ -103,7 +129,7 @@ func (h *HttpClient) SendStatusRequest(ctx *BeaconSendingContext) protocol.Statu
 	h.appendAdditionalQueryParameters(&b, ctx)
 
 	statusUrl := b.String()
-	r, err := h.sendRequest(STATUS, statusUrl, "", nil, "GET")
+	r, err := h.sendRequest(ctx, STATUS, statusUrl, "", nil, "GET")
 	if err != nil {
 		return protocol.NewStatusResponse(h.log, protocol.UndefinedResponseAttributes(), 999, nil)
 	}
@@ -117,7 +143,7 @@ func (h *HttpClient) SendNewSessionRequest(ctx *BeaconSendingContext) protocol.S
 	var b strings.Builder
 	b.WriteString(h.newSessionURL)
 	h.appendAdditionalQueryParameters(&b, ctx)
-	r, err := h.sendRequest(NEW_SESSION, b.String(), "", nil, "GET")
+	r, err := h.sendRequest(ctx, NEW_SESSION, b.String(), "", nil, "GET")
 	if err != nil {
 		return protocol.NewStatusResponse(h.log, protocol.UndefinedResponseAttributes(), -1, nil)
 	}
@@ -130,7 +156,7 @@ func (h *HttpClient) sendBeaconRequest(clientIPAddress string, data []byte, ctx
 	var b strings.Builder
 	b.WriteString(h.monitorURL)
 	h.appendAdditionalQueryParameters(&b, ctx)
-	r, err := h.sendRequest(BEACON, b.String(), clientIPAddress, data, "POST")
+	r, err := h.sendRequest(ctx, BEACON, b.String(), clientIPAddress, data, "POST")
 	if err != nil {
 		return protocol.NewStatusResponse(h.log, protocol.UndefinedResponseAttributes(), -1, nil)
 	}
@@ -138,15 +164,32 @@ func (h *HttpClient) sendBeaconRequest(clientIPAddress string, data []byte, ctx
 
 }
 
-func (h *HttpClient) sendRequest(requestType RequestType, url string, clientIPAddress string, data []byte, method string) (*protocol.StatusResponse, error) {
-	h.log.WithFields(log.Fields{"type": h.requestTypes[requestType], "url": url, "method": method}).Debug("sendRequest")
+// maxRetries returns how often a failed request is retried. Beacons are only retried if the policy asks for it,
+// status requests are retried by sendStatusRequest.
+func (h *HttpClient) maxRetries(requestType RequestType) int {
+	switch {
+	case requestType == BEACON && !h.retryPolicy.RetryBeacons:
+		return 0
+	case requestType == STATUS:
+		return 0
+	}
+	return h.retryPolicy.MaxRetries
+}
 
-	client := http.Client{Transport: h.transport}
+// retryBackoff returns the time to wait before the given retry, starting at 0
+func (h *HttpClient) retryBackoff(retry int) time.Duration {
+	return h.retryPolicy.Backoff(retry, h.random)
+}
+
+// sendRequest sends the request and retries it on connection errors and 5xx responses, see maxRetries
+func (h *HttpClient) sendRequest(ctx *BeaconSendingContext, requestType RequestType, url string, clientIPAddress string, data []byte, method string) (*protocol.StatusResponse, error) {
+	h.log.WithFields(log.Fields{"type": h.requestTypes[requestType], "url": url, "method": method}).Debug("sendRequest")
 
-	var buf bytes.Buffer
+	var body []byte
 
 	if data != nil {
 		h.log.WithFields(log.Fields{"data": string(data)}).Debug("Beacon Body")
+		var buf bytes.Buffer
 		g := gzip.NewWriter(&buf)
 
 		if _, err := g.Write(data); err != nil {
@@ -157,9 +200,34 @@ func (h *HttpClient) sendRequest(requestType RequestType, url string, clientIPAd
 			h.log.Error(err.Error())
 			return nil, err
 		}
+		body = buf.Bytes()
 	}
 
-	request, err := http.NewRequest(method, url, &buf)
+	maxRetries := h.maxRetries(requestType)
+	for retry := 0; ; retry++ {
+		statusResponse, err := h.doRequest(url, clientIPAddress, body, method)
+		if err == nil && statusResponse.ResponseCode < http.StatusInternalServerError {
+			return statusResponse, nil
+		}
+		if retry >= maxRetries || ctx.IsShutdownRequested() {
+			return statusResponse, err
+		}
+
+		backoff := h.retryBackoff(retry)
+		h.log.WithFields(log.Fields{"type": h.requestTypes[requestType], "retry": retry + 1, "backoff": backoff}).Debug("retrying request")
+		ctx.sleep(backoff)
+	}
+}
+
+func (h *HttpClient) doRequest(url string, clientIPAddress string, body []byte, method string) (*protocol.StatusResponse, error) {
+	requestCtx := context.Background()
+	if h.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		requestCtx, cancel = context.WithTimeout(requestCtx, h.requestTimeout)
+		defer cancel()
+	}
+
+	request, err := http.NewRequestWithContext(requestCtx, method, url, bytes.NewReader(body))
 	if err != nil {
 		h.log.Error(err.Error())
 		return nil, err
@@ -169,12 +237,16 @@ func (h *HttpClient) sendRequest(requestType RequestType, url string, clientIPAd
 		request.Header.Add("X-Client-IP", clientIPAddress)
 	}
 
-	resp, err := client.Do(request)
+	resp, err := h.client.Do(request)
 	if err != nil {
 		h.log.Error(err.Error())
 		return nil, err
 	}
-	defer resp.Body.Close()
+	defer func() {
+		// the rest of the body is read so that the connection can be reused
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
 
 	if resp.StatusCode >= http.StatusBadRequest {
 		h.log.WithFields(log.Fields{"response": resp.Status}).Warning("Bad response from OpenKit")
@@ -182,7 +254,11 @@ func (h *HttpClient) sendRequest(requestType RequestType, url string, clientIPAd
 
 	var bodyString string
 	if resp.StatusCode == http.StatusOK {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			h.log.Error(err.Error())
+			return nil, err
+		}
 		bodyString = string(bodyBytes)
 	}
 
@@ -1,9 +1,16 @@
 package core
 
 import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
 	"github.com/stretchr/testify/assert"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestBuildMonitorURL(t *testing.T) {
@@ -20,3 +27,164 @@ func TestSendStatusRequest(t *testing.T) {
 	assert.True(t, statusResponse.ResponseCode == http.StatusOK)
 
 }
+
+func TestSendRequestRetriesServerErrors(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(testStatusResponse))
+	}))
+	defer server.Close()
+
+	config := &configuration.HttpClientConfiguration{
+		BaseURL:     server.URL,
+		RetryPolicy: configuration.RetryPolicy{MaxRetries: 3, BaseBackoff: time.Millisecond},
+	}
+	client := NewHttpClient(logger, config)
+
+	statusResponse := client.SendNewSessionRequest(NewBeaconSendingContext(logger, config, providers.NewDefaultClock()))
+	assert.Equal(t, http.StatusOK, statusResponse.ResponseCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestSendRequestGivesUpAfterMaxRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	config := &configuration.HttpClientConfiguration{
+		BaseURL:     server.URL,
+		RetryPolicy: configuration.RetryPolicy{MaxRetries: 2, BaseBackoff: time.Millisecond},
+	}
+	client := NewHttpClient(logger, config)
+
+	statusResponse := client.SendNewSessionRequest(NewBeaconSendingContext(logger, config, providers.NewDefaultClock()))
+	assert.Equal(t, http.StatusBadGateway, statusResponse.ResponseCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestStatusRequestsAreRetriedOnErrorResponses(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(testStatusResponse))
+	}))
+	defer server.Close()
+
+	config := &configuration.HttpClientConfiguration{
+		BaseURL:     server.URL,
+		RetryPolicy: configuration.RetryPolicy{MaxRetries: 3, BaseBackoff: time.Millisecond},
+	}
+	ctx := NewBeaconSendingContext(logger, config, providers.NewDefaultClock())
+
+	// 4xx responses are retried in StateInit as well
+	statusResponse := sendStatusRequest(ctx)
+	assert.Equal(t, http.StatusOK, statusResponse.ResponseCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestStatusRequestsGiveUpAfterMaxRetries(t *testing.T) {
+	var requests int32
+	code := int32(http.StatusBadGateway)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&code)))
+	}))
+	defer server.Close()
+
+	config := &configuration.HttpClientConfiguration{
+		BaseURL:     server.URL,
+		RetryPolicy: configuration.RetryPolicy{MaxRetries: 2, BaseBackoff: time.Millisecond},
+	}
+	ctx := NewBeaconSendingContext(logger, config, providers.NewDefaultClock())
+
+	statusResponse := sendStatusRequest(ctx)
+	assert.Equal(t, http.StatusBadGateway, statusResponse.ResponseCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests), "the client itself does not retry status requests")
+
+	// 429 is handled by the states
+	atomic.StoreInt32(&code, http.StatusTooManyRequests)
+	statusResponse = sendStatusRequest(ctx)
+	assert.Equal(t, http.StatusTooManyRequests, statusResponse.ResponseCode)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+}
+
+func TestErrorResponsesKeepTheConnection(t *testing.T) {
+	var connections int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		// the body is too large for the transport to read it when it is closed
+		w.Write([]byte(strings.Repeat("unavailable", 50000)))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	config := &configuration.HttpClientConfiguration{
+		BaseURL:     server.URL,
+		RetryPolicy: configuration.RetryPolicy{MaxRetries: 3, BaseBackoff: time.Millisecond},
+	}
+	client := NewHttpClient(logger, config)
+
+	statusResponse := client.SendNewSessionRequest(NewBeaconSendingContext(logger, config, providers.NewDefaultClock()))
+	assert.Equal(t, http.StatusServiceUnavailable, statusResponse.ResponseCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&connections))
+}
+
+func TestBeaconsAreOnlyRetriedIfEnabled(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := &configuration.HttpClientConfiguration{
+		BaseURL:     server.URL,
+		RetryPolicy: configuration.RetryPolicy{MaxRetries: 2, BaseBackoff: time.Millisecond},
+	}
+	ctx := NewBeaconSendingContext(logger, config, providers.NewDefaultClock())
+
+	client := NewHttpClient(logger, config)
+	statusResponse := client.sendBeaconRequest("", []byte("et=19"), ctx)
+	assert.Equal(t, http.StatusServiceUnavailable, statusResponse.ResponseCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	config.RetryPolicy.RetryBeacons = true
+	client = NewHttpClient(logger, config)
+	client.sendBeaconRequest("", []byte("et=19"), ctx)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+}
+
+func TestSendRequestTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	config := &configuration.HttpClientConfiguration{
+		BaseURL:        server.URL,
+		RequestTimeout: 50 * time.Millisecond,
+	}
+	client := NewHttpClient(logger, config)
+
+	start := time.Now()
+	statusResponse := client.SendStatusRequest(NewBeaconSendingContext(logger, config, providers.NewDefaultClock()))
+	assert.True(t, statusResponse.IsErroneousResponse())
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
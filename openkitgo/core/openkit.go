@@ -1,52 +1,78 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/redaction"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/utils"
-	log "github.com/sirupsen/logrus"
-	"net/http"
 	"sync"
 	"time"
 )
 
 type OpenKit struct {
-	log                  *log.Logger
+	log                  log.Logger
 	privacyConfiguration *configuration.PrivacyConfiguration
 	openKitConfiguration *configuration.OpenKitConfiguration
-	beaconCache          *caching.BeaconCache
+	beaconCache          caching.BeaconCache
 	beaconCacheEvictor   *caching.BeaconCacheEvictor
 	beaconSender         *BeaconSender
+	isInitialized        bool
 	isShutDown           bool
+	shutdownDone         chan struct{}
 	mutex                sync.RWMutex
 	sessionWatchdog      *SessionWatchdog
+	clock                providers.Clock
+
+	sessionIDProvider     providers.SessionIDProvider
+	randomNumberGenerator providers.RandomNumberGenerator
+	threadIDProvider      providers.ThreadIDProvider
+	redactor              *redaction.Redactor
+	beforeSend            interfaces.BeforeSendFunc
 
 	children []OpenKitObject
 }
 
 func (o *OpenKit) CreateSessionWithDeviceID(clientIPAddress string, deviceID int64) interfaces.Session {
-	return o.CreateSessionAtWithDeviceID(clientIPAddress, time.Now(), deviceID)
+	return o.CreateSessionAtWithDeviceID(clientIPAddress, o.clock.Now(), deviceID)
 
 }
 
 func (o *OpenKit) CreateSessionAtWithDeviceID(clientIPAddress string, timestamp time.Time, deviceID int64) interfaces.Session {
-	o.log.WithFields(log.Fields{"clientIPAddress": clientIPAddress, "timestamp": timestamp}).Debug("OpenKit.CreateSessionAt()")
+	return o.CreateSessionWithOptions(interfaces.SessionOptions{
+		ClientIP:  clientIPAddress,
+		DeviceID:  &deviceID,
+		Timestamp: timestamp,
+	})
+}
+
+// CreateSessionWithStringDeviceID converts deviceID with DeviceIDFromString
+func (o *OpenKit) CreateSessionWithStringDeviceID(clientIPAddress string, deviceID string) interfaces.Session {
+	return o.CreateSessionWithDeviceID(clientIPAddress, DeviceIDFromString(deviceID))
+}
+
+func (o *OpenKit) CreateSessionWithOptions(options interfaces.SessionOptions) interfaces.Session {
+	if options.Timestamp.IsZero() {
+		options.Timestamp = o.clock.Now()
+	}
+	o.log.WithFields(log.Fields{"clientIPAddress": options.ClientIP, "timestamp": options.Timestamp}).Debug("OpenKit.CreateSessionWithOptions()")
 
 	o.mutex.Lock()
 	defer o.mutex.Unlock()
 	if !o.isShutDown {
 
-		o.openKitConfiguration.DeviceID = deviceID
 		sessionProxy := NewSessionProxy(
 			o.log,
 			o,
 			o.beaconSender,
 			o.sessionWatchdog,
 			o,
-			clientIPAddress,
-			timestamp,
+			options,
 		)
 
 		o.storeChildInList(sessionProxy)
@@ -57,19 +83,18 @@ func (o *OpenKit) CreateSessionAtWithDeviceID(clientIPAddress string, timestamp
 }
 
 func (o *OpenKit) CreateSession(clientIPAddress string) interfaces.Session {
-	return o.CreateSessionAtWithDeviceID(clientIPAddress, time.Now(), o.openKitConfiguration.DeviceID)
+	return o.CreateSessionWithOptions(interfaces.SessionOptions{ClientIP: clientIPAddress})
 }
 
 func (o *OpenKit) CreateSessionAt(clientIPAddress string, timestamp time.Time) interfaces.Session {
-	return o.CreateSessionAtWithDeviceID(clientIPAddress, timestamp, o.openKitConfiguration.DeviceID)
+	return o.CreateSessionWithOptions(interfaces.SessionOptions{ClientIP: clientIPAddress, Timestamp: timestamp})
 }
 
 func NewOpenKit(builder *OpenKitBuilder) interfaces.OpenKit {
 
-	privacyConfig := &configuration.PrivacyConfiguration{
-		DataCollectionLevel: builder.dataCollectionLevel,
-		CrashReportingLevel: builder.crashReportLevel,
-	}
+	privacyConfig := configuration.NewPrivacyConfiguration(builder.dataCollectionLevel, builder.crashReportLevel)
+
+	client := builder.buildHTTPClient()
 
 	openKitConfig := &configuration.OpenKitConfiguration{
 		EndpointURL:                 builder.endpointURL,
@@ -84,26 +109,45 @@ func NewOpenKit(builder *OpenKitBuilder) interfaces.OpenKit {
 		Manufacturer:                builder.manufacturer,
 		ModelID:                     builder.modelID,
 		DefaultServerID:             DEFAULT_SERVER_ID,
-		Transport:                   &http.Transport{},
+		HttpClient:                  client,
 	}
 
-	beaconCache := caching.NewBeaconCache(builder.log)
 	beaconCacheConfig := configuration.NewBeaconCacheConfiguration(
 		builder.beaconCacheMaxRecordAge,
 		builder.beaconCacheLowerMemoryBoundary,
 		builder.beaconCacheUpperMemoryBoundary)
-	beaconCacheEvictor := caching.NewBeaconCacheEvictor(builder.log, beaconCache, beaconCacheConfig)
+	beaconCacheConfig.EvictionInterval = builder.beaconCacheEvictionInterval
+	beaconCache := builder.beaconCache
+	if beaconCache == nil && builder.beaconCacheDirectory != "" {
+		persistentCache, err := caching.NewPersistentBeaconCache(builder.log, builder.beaconCacheDirectory, beaconCacheConfig, builder.clock)
+		if err != nil {
+			// BuildE reports this as a validation error, Build keeps the data in memory instead
+			builder.log.WithFields(log.Fields{"dir": builder.beaconCacheDirectory, "error": err}).Error("could not open the beacon cache directory, unsent data is only kept in memory")
+		} else {
+			beaconCache = persistentCache
+		}
+	}
+	if beaconCache == nil {
+		beaconCache = caching.NewBeaconCache(builder.log)
+	}
+	cacheNotifier := &caching.Notifier{}
+	beaconCache = caching.NewNotifyingBeaconCache(beaconCache, cacheNotifier)
+	beaconCacheEvictor := caching.NewBeaconCacheEvictor(builder.log, beaconCache, cacheNotifier, beaconCacheConfig, builder.clock)
 
 	httpClientConfig := &configuration.HttpClientConfiguration{
-		BaseURL:       builder.endpointURL,
-		ServerID:      DEFAULT_SERVER_ID,
-		ApplicationID: builder.applicationID,
-		Transport:     builder.transport,
-		Technology:    builder.technology,
+		BaseURL:        builder.endpointURL,
+		ServerID:       DEFAULT_SERVER_ID,
+		ApplicationID:  builder.applicationID,
+		Client:         client,
+		RetryPolicy:    builder.retryPolicy,
+		RequestTimeout: builder.requestTimeout,
+		Technology:     builder.technology,
+
+		RandomNumberGenerator: builder.randomNumberGenerator,
 	}
 
-	beaconSender := NewBeaconSender(builder.log, httpClientConfig)
-	sessionWatchdog := NewSessionWatchdog(builder.log, NewSessionWatchdogContext())
+	beaconSender := NewBeaconSender(builder.log, httpClientConfig, builder.clock)
+	sessionWatchdog := NewSessionWatchdog(builder.log, NewSessionWatchdogContext(builder.clock))
 
 	ok := &OpenKit{
 		log:                  builder.log,
@@ -113,12 +157,22 @@ func NewOpenKit(builder *OpenKitBuilder) interfaces.OpenKit {
 		beaconCacheEvictor:   beaconCacheEvictor,
 		beaconSender:         beaconSender,
 		sessionWatchdog:      sessionWatchdog,
+		shutdownDone:         make(chan struct{}),
+		clock:                builder.clock,
+
+		sessionIDProvider:     builder.buildSessionIDProvider(),
+		randomNumberGenerator: builder.randomNumberGenerator,
+		threadIDProvider:      builder.threadIDProvider,
+		redactor:              builder.redactor,
+		beforeSend:            builder.beforeSend,
 	}
+	ok.recoverSessions()
 
 	return ok
 }
 
 func (o *OpenKit) initialize() {
+	o.isInitialized = true
 
 	o.beaconCacheEvictor.Start()
 	o.sessionWatchdog.Initialize()
@@ -142,7 +196,7 @@ func (o *OpenKit) DetailedString() string {
 }
 
 func (o *OpenKit) close() {
-	o.closeAt(time.Now())
+	o.closeAt(o.clock.Now())
 }
 
 func (o *OpenKit) closeAt(timestamp time.Time) {
@@ -157,8 +211,99 @@ func (o *OpenKit) WaitForInitCompletionTimeout(duration time.Duration) bool {
 	return o.beaconSender.WaitForInitTimeout(duration)
 }
 
+func (o *OpenKit) SetDataCollectionLevel(level configuration.DataCollectionLevel) {
+	o.log.WithFields(log.Fields{"level": level}).Debug("OpenKit.SetDataCollectionLevel()")
+	o.privacyConfiguration.SetDataCollectionLevel(level)
+}
+
+func (o *OpenKit) SetCrashReportingLevel(level configuration.CrashReportingLevel) {
+	o.log.WithFields(log.Fields{"level": level}).Debug("OpenKit.SetCrashReportingLevel()")
+	o.privacyConfiguration.SetCrashReportingLevel(level)
+}
+
+var (
+	ErrShutDown       = errors.New("OpenKit is shut down")
+	ErrCaptureOff     = errors.New("data capturing is turned off")
+	ErrNotInitialized = errors.New("OpenKit could not reach the beacon endpoint yet")
+)
+
+// FlushError is returned by Flush if sending data failed. ResponseCode is the one of the last failed request,
+// it is negative if no response was received.
+type FlushError struct {
+	ResponseCode   int
+	FailedRequests int
+}
+
+func (e *FlushError) Error() string {
+	return fmt.Sprintf("OpenKit flush failed, %d requests failed, the last with response code %d", e.FailedRequests, e.ResponseCode)
+}
+
+// ShutdownError is returned by ShutdownContext if the context expires before all data was sent.
+// UnsentBytes only counts data still waiting in the beacon cache, not the beacon being sent.
+type ShutdownError struct {
+	Err            error
+	UnsentSessions int
+	UnsentBytes    int64
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("OpenKit shutdown did not complete (%s), %d sessions with %d bytes of data were not sent", e.Err, e.UnsentSessions, e.UnsentBytes)
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// Flush sends all cached data of new, finished and open sessions right away and blocks until
+// it was sent or ctx expires. Sessions are not ended.
+func (o *OpenKit) Flush(ctx context.Context) error {
+	o.log.Debug("OpenKit.Flush()")
+	o.mutex.RLock()
+	isShutDown := o.isShutDown
+	o.mutex.RUnlock()
+	if isShutDown {
+		return ErrShutDown
+	}
+	if o.beaconSender.IsInitialized() && !o.beaconSender.isCaptureOn() {
+		return ErrCaptureOff
+	}
+
+	select {
+	case err := <-o.beaconSender.Flush():
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown ends all open sessions and returns without waiting for their data to be sent, the final flush happens
+// in the background. Use ShutdownContext to wait for it.
 func (o *OpenKit) Shutdown() {
-	o.log.Debug("OpenKit.shutdown()")
+	o.log.Debug("OpenKit.Shutdown()")
+	o.startShutdown()
+}
+
+// ShutdownContext ends all open sessions and blocks until they were sent and all background goroutines stopped,
+// or until ctx expires
+func (o *OpenKit) ShutdownContext(ctx context.Context) error {
+	o.log.Debug("OpenKit.ShutdownContext()")
+	o.startShutdown()
+
+	select {
+	case <-o.shutdownDone:
+		return nil
+	case <-ctx.Done():
+		return &ShutdownError{
+			Err:            ctx.Err(),
+			UnsentSessions: o.beaconSender.getSessionCount(),
+			UnsentBytes:    o.beaconCache.GetNumBytesInCache(),
+		}
+	}
+}
+
+// startShutdown ends the children and the beacon sender on the first call, the rest of the shutdown runs in
+// finishShutdown
+func (o *OpenKit) startShutdown() {
 	o.mutex.Lock()
 	defer o.mutex.Unlock()
 	if o.isShutDown {
@@ -170,10 +315,32 @@ func (o *OpenKit) Shutdown() {
 		child.close()
 	}
 
-	o.beaconCacheEvictor.Stop()
-	o.sessionWatchdog.Shutdown()
 	o.beaconSender.Shutdown()
+	go o.finishShutdown()
+}
+
+// finishShutdown waits for the beacon sender to send the remaining data, then flushes the beacon cache and stops
+// the remaining goroutines. shutdownDone is closed once everything stopped.
+func (o *OpenKit) finishShutdown() {
+	defer close(o.shutdownDone)
+
+	if o.isInitialized {
+		<-o.beaconSender.Done()
+	}
 
+	if flusher, ok := o.beaconCache.(caching.Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			o.log.WithFields(log.Fields{"error": err.Error()}).Warning("OpenKit could not flush the beacon cache")
+		}
+	}
+
+	// The evictor is stopped last, the beacon sender still adds data to the cache while flushing
+	o.sessionWatchdog.Shutdown()
+	o.beaconCacheEvictor.Stop()
+	if o.isInitialized {
+		<-o.sessionWatchdog.Done()
+		<-o.beaconCacheEvictor.Done()
+	}
 }
 
 func (o *OpenKit) getCopyOfChildObjects() []OpenKitObject {
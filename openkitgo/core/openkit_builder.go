@@ -2,12 +2,22 @@ package core
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/protocol"
-	log "github.com/sirupsen/logrus"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/redaction"
+	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -26,9 +36,26 @@ type OpenKitBuilder struct {
 	deviceID     int64
 	origDeviceID string
 
-	log                            *log.Logger
+	log                            log.Logger
+	httpClient                     *http.Client
+	roundTripper                   http.RoundTripper
 	transport                      *http.Transport
+	caCertificates                 *x509.CertPool
+	clientCertificates             []tls.Certificate
+	insecureSkipVerify             bool
+	connectTimeout                 time.Duration
+	readTimeout                    time.Duration
+	requestTimeout                 time.Duration
+	retryPolicy                    configuration.RetryPolicy
+	clock                          providers.Clock
+	randomNumberGenerator          providers.RandomNumberGenerator
+	sessionIDProvider              providers.SessionIDProvider
+	threadIDProvider               providers.ThreadIDProvider
+	redactor                       *redaction.Redactor
+	beforeSend                     interfaces.BeforeSendFunc
+	errs                           ValidationErrors
 	logLevel                       log.Level
+	isLogLevelSet                  bool
 	operatingSystem                string
 	manufacturer                   string
 	modelID                        string
@@ -36,6 +63,9 @@ type OpenKitBuilder struct {
 	beaconCacheMaxRecordAge        time.Duration
 	beaconCacheLowerMemoryBoundary int64
 	beaconCacheUpperMemoryBoundary int64
+	beaconCacheEvictionInterval    time.Duration
+	beaconCacheDirectory           string
+	beaconCache                    caching.BeaconCache
 	dataCollectionLevel            configuration.DataCollectionLevel
 	crashReportLevel               configuration.CrashReportingLevel
 	technology                     string
@@ -59,8 +89,8 @@ func NewOpenKitBuilder(endpointURL string, applicationID string, deviceID int64)
 		applicationID:                  applicationID,
 		deviceID:                       deviceID,
 		origDeviceID:                   strconv.FormatInt(deviceID, 10),
-		log:                            log.New(),
-		transport:                      &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		log:                            log.NewLogrusLogger(nil),
+		logLevel:                       log.InfoLevel,
 		operatingSystem:                DEFAULT_OPERATING_SYSTEM,
 		manufacturer:                   DEFAULT_MANUFACTURER,
 		modelID:                        DEFAULT_MODEL_ID,
@@ -68,42 +98,268 @@ func NewOpenKitBuilder(endpointURL string, applicationID string, deviceID int64)
 		beaconCacheMaxRecordAge:        configuration.DEFAULT_MAX_RECORD_AGE,
 		beaconCacheLowerMemoryBoundary: configuration.DEFAULT_LOWER_MEMORY_BOUNDARY_IN_BYTES,
 		beaconCacheUpperMemoryBoundary: configuration.DEFAULT_UPPER_MEMORY_BOUNDARY_IN_BYTES,
+		beaconCacheEvictionInterval:    configuration.DEFAULT_EVICTION_INTERVAL,
 		dataCollectionLevel:            configuration.DEFAULT_DATA_COLLECTION_LEVEL,
 		crashReportLevel:               configuration.DEFAULT_CRASH_REPORTING_LEVEL,
 		technology:                     protocol.AGENT_TECHNOLOGY_TYPE,
+		clock:                          providers.NewDefaultClock(),
+		randomNumberGenerator:          providers.NewDefaultRandomNumberGenerator(),
+		threadIDProvider:               providers.NewFixedThreadIDProvider(1),
+		connectTimeout:                 CONNECT_TIMEOUT,
+		readTimeout:                    READ_TIMEOUT,
+		requestTimeout:                 REQUEST_TIMEOUT,
+		retryPolicy: configuration.RetryPolicy{
+			MaxRetries:  MAX_SEND_RETRIES,
+			BaseBackoff: RETRY_SLEEP_TIME,
+			MaxBackoff:  MAX_RETRY_SLEEP_TIME,
+			Jitter:      RETRY_JITTER,
+		},
 	}
 
 }
 
+// NewOpenKitBuilderWithStringDeviceID creates a builder for a string device ID, see DeviceIDFromString
+func NewOpenKitBuilderWithStringDeviceID(endpointURL string, applicationID string, deviceID string) interfaces.OpenKitBuilder {
+	b := NewOpenKitBuilder(endpointURL, applicationID, DeviceIDFromString(deviceID)).(*OpenKitBuilder)
+	b.origDeviceID = deviceID
+	return b
+}
+
 func (b *OpenKitBuilder) WithApplicationName(applicationName string) interfaces.OpenKitBuilder {
 	b.applicationName = applicationName
 	return b
 }
 
+// WithLogLevel changes the level of loggers that implement logging.LevelSetter, like the default logrus logger
 func (b *OpenKitBuilder) WithLogLevel(level log.Level) interfaces.OpenKitBuilder {
 	b.logLevel = level
-	b.log.SetLevel(level)
+	b.isLogLevelSet = true
+	b.applyLogLevel()
 	return b
 }
 
-func (b *OpenKitBuilder) WithLogger(log *log.Logger) interfaces.OpenKitBuilder {
-	b.log = log
+// WithLogger replaces the logger, a level set before with WithLogLevel or the log_level setting is applied to it
+func (b *OpenKitBuilder) WithLogger(logger log.Logger) interfaces.OpenKitBuilder {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	b.log = logger
+	if b.isLogLevelSet {
+		b.applyLogLevel()
+	}
 	return b
 }
 
+func (b *OpenKitBuilder) applyLogLevel() {
+	if setter, ok := b.log.(log.LevelSetter); ok {
+		setter.SetLevel(b.logLevel)
+	}
+}
+
 func (b *OpenKitBuilder) WithApplicationVersion(version string) interfaces.OpenKitBuilder {
 	b.applicationVersion = version
 	return b
 }
 
+// WithTransport sets the transport used to talk to the beacon endpoint, nil restores the default transport.
+// TLS options of the builder are applied to a copy of it.
 func (b *OpenKitBuilder) WithTransport(transport *http.Transport) interfaces.OpenKitBuilder {
-	if transport == nil {
-		transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-	}
 	b.transport = transport
 	return b
 }
 
+// WithHTTPClient sets the client shared by all requests to the beacon endpoint.
+// The client is used as is, transport and TLS options of the builder do not apply to it.
+func (b *OpenKitBuilder) WithHTTPClient(client *http.Client) interfaces.OpenKitBuilder {
+	b.httpClient = client
+	return b
+}
+
+// WithRoundTripper sets the round tripper used for all requests to the beacon endpoint.
+// Transport and TLS options of the builder do not apply to it.
+func (b *OpenKitBuilder) WithRoundTripper(roundTripper http.RoundTripper) interfaces.OpenKitBuilder {
+	b.roundTripper = roundTripper
+	return b
+}
+
+// WithClock replaces the clock used for timestamps, send intervals, session splitting and cache eviction
+func (b *OpenKitBuilder) WithClock(clock providers.Clock) interfaces.OpenKitBuilder {
+	if clock == nil {
+		clock = providers.NewDefaultClock()
+	}
+	b.clock = clock
+	return b
+}
+
+// WithRandomSource draws session IDs and traffic control sampling from source, a seeded source makes them reproducible
+func (b *OpenKitBuilder) WithRandomSource(source rand.Source) interfaces.OpenKitBuilder {
+	return b.WithRandomNumberGenerator(providers.NewRandomNumberGenerator(source))
+}
+
+// WithRandomNumberGenerator replaces the generator for session IDs and traffic control sampling
+func (b *OpenKitBuilder) WithRandomNumberGenerator(random providers.RandomNumberGenerator) interfaces.OpenKitBuilder {
+	if random == nil {
+		random = providers.NewDefaultRandomNumberGenerator()
+	}
+	b.randomNumberGenerator = random
+	return b
+}
+
+// WithSessionIDProvider replaces the provider of session numbers, by default they start at a random offset
+func (b *OpenKitBuilder) WithSessionIDProvider(provider providers.SessionIDProvider) interfaces.OpenKitBuilder {
+	b.sessionIDProvider = provider
+	return b
+}
+
+// WithThreadIDProvider replaces the provider of the thread ID sent with every event
+func (b *OpenKitBuilder) WithThreadIDProvider(provider providers.ThreadIDProvider) interfaces.OpenKitBuilder {
+	if provider == nil {
+		provider = providers.NewFixedThreadIDProvider(1)
+	}
+	b.threadIDProvider = provider
+	return b
+}
+
+// WithRedactor removes personal data from names, values, URLs, errors and user tags before they are cached.
+// Redaction is off by default.
+func (b *OpenKitBuilder) WithRedactor(redactor *redaction.Redactor) interfaces.OpenKitBuilder {
+	b.redactor = redactor
+	return b
+}
+
+// WithBeforeSend sets a hook that can inspect, change or drop every event before it is cached.
+// Redaction is applied to the events the hook returns.
+func (b *OpenKitBuilder) WithBeforeSend(beforeSend func(event *interfaces.Event) *interfaces.Event) interfaces.OpenKitBuilder {
+	b.beforeSend = beforeSend
+	return b
+}
+
+// WithConnectTimeout limits the time to establish a connection to the beacon endpoint.
+// It does not apply to transports passed to WithTransport that have their own dialer.
+func (b *OpenKitBuilder) WithConnectTimeout(timeout time.Duration) interfaces.OpenKitBuilder {
+	b.connectTimeout = timeout
+	return b
+}
+
+// WithReadTimeout limits the time to wait for the response headers after a request was written.
+// It does not apply to transports passed to WithTransport that have their own response header timeout.
+func (b *OpenKitBuilder) WithReadTimeout(timeout time.Duration) interfaces.OpenKitBuilder {
+	b.readTimeout = timeout
+	return b
+}
+
+// WithRequestTimeout limits the overall time of a single request attempt, including reading the response
+func (b *OpenKitBuilder) WithRequestTimeout(timeout time.Duration) interfaces.OpenKitBuilder {
+	b.requestTimeout = timeout
+	return b
+}
+
+func (b *OpenKitBuilder) WithRetryPolicy(policy configuration.RetryPolicy) interfaces.OpenKitBuilder {
+	b.retryPolicy = policy
+	return b
+}
+
+// WithCACertificates trusts the PEM encoded certificates instead of the system roots
+func (b *OpenKitBuilder) WithCACertificates(pemCerts []byte) interfaces.OpenKitBuilder {
+	if b.caCertificates == nil {
+		b.caCertificates = x509.NewCertPool()
+	}
+	if !b.caCertificates.AppendCertsFromPEM(pemCerts) {
+		b.errs = append(b.errs, &ValidationError{Field: "caCertificates", Reason: "no certificates found in PEM data"})
+	}
+	return b
+}
+
+// WithCACertificatesFile trusts the PEM encoded certificates in file instead of the system roots
+func (b *OpenKitBuilder) WithCACertificatesFile(file string) interfaces.OpenKitBuilder {
+	pemCerts, err := ioutil.ReadFile(file)
+	if err != nil {
+		b.errs = append(b.errs, &ValidationError{Field: "caCertificates", Reason: err.Error()})
+		return b
+	}
+	return b.WithCACertificates(pemCerts)
+}
+
+// WithClientCertificate presents the PEM encoded certificate and key for mutual TLS
+func (b *OpenKitBuilder) WithClientCertificate(certPEM []byte, keyPEM []byte) interfaces.OpenKitBuilder {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		b.errs = append(b.errs, &ValidationError{Field: "clientCertificate", Reason: err.Error()})
+		return b
+	}
+	b.clientCertificates = append(b.clientCertificates, cert)
+	return b
+}
+
+// WithClientCertificateFile presents the PEM encoded certificate and key in the given files for mutual TLS
+func (b *OpenKitBuilder) WithClientCertificateFile(certFile string, keyFile string) interfaces.OpenKitBuilder {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		b.errs = append(b.errs, &ValidationError{Field: "clientCertificate", Reason: err.Error()})
+		return b
+	}
+	b.clientCertificates = append(b.clientCertificates, cert)
+	return b
+}
+
+// WithInsecureSkipVerify disables the verification of the server certificate. Do not use this in production.
+func (b *OpenKitBuilder) WithInsecureSkipVerify() interfaces.OpenKitBuilder {
+	b.insecureSkipVerify = true
+	return b
+}
+
+// buildSessionIDProvider returns the provider shared by all sessions of one OpenKit
+func (b *OpenKitBuilder) buildSessionIDProvider() providers.SessionIDProvider {
+	if b.sessionIDProvider != nil {
+		return b.sessionIDProvider
+	}
+	return providers.NewSessionIDProvider(b.randomNumberGenerator)
+}
+
+func (b *OpenKitBuilder) buildHTTPClient() *http.Client {
+	if b.httpClient != nil {
+		return b.httpClient
+	}
+	if b.roundTripper != nil {
+		return &http.Client{Transport: b.roundTripper}
+	}
+	return &http.Client{Transport: b.buildTransport()}
+}
+
+func (b *OpenKitBuilder) buildTransport() *http.Transport {
+	var transport *http.Transport
+	if b.transport != nil {
+		transport = b.transport.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = nil
+	}
+
+	if transport.DialContext == nil && transport.Dial == nil {
+		transport.DialContext = (&net.Dialer{Timeout: b.connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if transport.ResponseHeaderTimeout == 0 {
+		transport.ResponseHeaderTimeout = b.readTimeout
+	}
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	if b.caCertificates != nil {
+		transport.TLSClientConfig.RootCAs = b.caCertificates
+	}
+	if len(b.clientCertificates) > 0 {
+		transport.TLSClientConfig.Certificates = append(transport.TLSClientConfig.Certificates, b.clientCertificates...)
+	}
+	if b.insecureSkipVerify {
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	if transport.TLSClientConfig.InsecureSkipVerify {
+		b.log.WithFields(log.Fields{"endpointURL": b.endpointURL}).Warning("TLS certificate verification is disabled")
+	}
+	return transport
+}
+
 func (b *OpenKitBuilder) WithOperatingSystem(operatingSystem string) interfaces.OpenKitBuilder {
 	b.operatingSystem = operatingSystem
 	return b
@@ -134,6 +390,27 @@ func (b *OpenKitBuilder) WithBeaconCacheUpperMemoryBoundary(m int64) interfaces.
 	return b
 }
 
+// WithBeaconCacheEvictionInterval sets how often records older than the max record age are evicted
+// when no new data is added
+func (b *OpenKitBuilder) WithBeaconCacheEvictionInterval(interval time.Duration) interfaces.OpenKitBuilder {
+	b.beaconCacheEvictionInterval = interval
+	return b
+}
+
+// WithBeaconCacheDirectory keeps unsent data in dir, so it survives crashes and restarts and is sent
+// by the next OpenKit that uses the same directory. Two OpenKits must not share a directory at the same time.
+func (b *OpenKitBuilder) WithBeaconCacheDirectory(dir string) interfaces.OpenKitBuilder {
+	b.beaconCacheDirectory = dir
+	return b
+}
+
+// WithBeaconCache replaces the in-memory beacon cache with another storage backend.
+// It takes precedence over WithBeaconCacheDirectory.
+func (b *OpenKitBuilder) WithBeaconCache(cache caching.BeaconCache) interfaces.OpenKitBuilder {
+	b.beaconCache = cache
+	return b
+}
+
 func (b *OpenKitBuilder) WithDataCollectionLevel(l configuration.DataCollectionLevel) interfaces.OpenKitBuilder {
 	b.dataCollectionLevel = l
 	return b
@@ -149,13 +426,98 @@ func (b *OpenKitBuilder) WithTechnology(technology string) interfaces.OpenKitBui
 	return b
 }
 
+// Validate checks the builder settings and returns ValidationErrors with every invalid setting
+func (b *OpenKitBuilder) Validate() error {
+	errs := append(ValidationErrors{}, b.errs...)
+	invalid := func(field string, reason string) {
+		errs = append(errs, &ValidationError{Field: field, Reason: reason})
+	}
+
+	if u, err := url.Parse(b.endpointURL); err != nil {
+		invalid("endpointURL", err.Error())
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		invalid("endpointURL", fmt.Sprintf("scheme must be http or https, got %q", u.Scheme))
+	} else if u.Host == "" {
+		invalid("endpointURL", "host must not be empty")
+	}
+	if strings.TrimSpace(b.applicationID) == "" {
+		invalid("applicationID", "must not be empty")
+	}
+	if strings.TrimSpace(b.origDeviceID) == "" {
+		invalid("deviceID", "must not be empty")
+	}
+
+	if b.beaconCacheMaxRecordAge < 0 {
+		invalid("beaconCacheMaxRecordAge", "must not be negative")
+	}
+	if b.beaconCacheLowerMemoryBoundary < 0 {
+		invalid("beaconCacheLowerMemoryBoundary", "must not be negative")
+	}
+	if b.beaconCacheUpperMemoryBoundary < 0 {
+		invalid("beaconCacheUpperMemoryBoundary", "must not be negative")
+	}
+	if b.beaconCacheLowerMemoryBoundary > b.beaconCacheUpperMemoryBoundary {
+		invalid("beaconCacheLowerMemoryBoundary", "must not be greater than beaconCacheUpperMemoryBoundary")
+	}
+	if b.beaconCacheEvictionInterval <= 0 {
+		invalid("beaconCacheEvictionInterval", "must be positive")
+	}
+	if b.beaconCache == nil && b.beaconCacheDirectory != "" {
+		if err := caching.CheckBeaconCacheDirectory(b.beaconCacheDirectory); err != nil {
+			invalid("beaconCacheDirectory", err.Error())
+		}
+	}
+
+	if b.dataCollectionLevel < configuration.DATA_OFF || b.dataCollectionLevel > configuration.DATA_USER_BEHAVIOR {
+		invalid("dataCollectionLevel", fmt.Sprintf("unknown level %d", b.dataCollectionLevel))
+	}
+	if b.crashReportLevel < configuration.CRASH_OFF || b.crashReportLevel > configuration.CRASH_OPT_IN_CRASHES {
+		invalid("crashReportingLevel", fmt.Sprintf("unknown level %d", b.crashReportLevel))
+	}
+
+	if b.connectTimeout < 0 {
+		invalid("connectTimeout", "must not be negative")
+	}
+	if b.readTimeout < 0 {
+		invalid("readTimeout", "must not be negative")
+	}
+	if b.requestTimeout < 0 {
+		invalid("requestTimeout", "must not be negative")
+	}
+	if b.retryPolicy.MaxRetries < 0 || b.retryPolicy.BaseBackoff < 0 || b.retryPolicy.MaxBackoff < 0 {
+		invalid("retryPolicy", "must not contain negative values")
+	}
+	if b.retryPolicy.Jitter < 0 || b.retryPolicy.Jitter > 1 {
+		invalid("retryPolicy", "jitter must be between 0 and 1")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// BuildE validates the builder settings and returns ValidationErrors instead of an OpenKit if any are invalid
+func (b *OpenKitBuilder) BuildE() (interfaces.OpenKit, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return b.Build(), nil
+}
+
 func (b *OpenKitBuilder) Build() interfaces.OpenKit {
 
+	if errs, ok := b.Validate().(ValidationErrors); ok {
+		for _, err := range errs {
+			b.log.WithFields(log.Fields{"field": err.Field, "reason": err.Reason}).Warning("invalid OpenKit configuration")
+		}
+	}
+
 	openKit := NewOpenKit(b).(*OpenKit)
 	openKit.initialize()
 
-	log.WithFields(log.Fields{"instance": openKit.String()}).Info("OpenKit instantiated")
-	log.WithFields(log.Fields{"instance": openKit.DetailedString()}).Debug("OpenKit instantiated")
+	b.log.WithFields(log.Fields{"instance": openKit.String()}).Info("OpenKit instantiated")
+	b.log.WithFields(log.Fields{"instance": openKit.DetailedString()}).Debug("OpenKit instantiated")
 
 	return openKit
 
@@ -0,0 +1,199 @@
+package core
+
+import (
+	"fmt"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SETTING_ENDPOINT_URL            = "endpoint_url"
+	SETTING_APPLICATION_ID          = "application_id"
+	SETTING_DEVICE_ID               = "device_id"
+	SETTING_CLIENT_CERTIFICATE_FILE = "client_certificate_file"
+	SETTING_CLIENT_KEY_FILE         = "client_key_file"
+)
+
+type builderSetting func(b *OpenKitBuilder, value string) error
+
+// builderSettings maps the keys of configuration files and environment variables to the builder fields
+var builderSettings = map[string]builderSetting{
+	SETTING_ENDPOINT_URL:   func(b *OpenKitBuilder, v string) error { b.endpointURL = v; return nil },
+	SETTING_APPLICATION_ID: func(b *OpenKitBuilder, v string) error { b.applicationID = v; return nil },
+	SETTING_DEVICE_ID: func(b *OpenKitBuilder, v string) error {
+		if strings.TrimSpace(v) == "" {
+			return fmt.Errorf("must not be empty")
+		}
+		b.deviceID = DeviceIDFromString(v)
+		b.origDeviceID = v
+		return nil
+	},
+	"application_name":    func(b *OpenKitBuilder, v string) error { b.applicationName = v; return nil },
+	"application_version": func(b *OpenKitBuilder, v string) error { b.applicationVersion = v; return nil },
+	"operating_system":    func(b *OpenKitBuilder, v string) error { b.operatingSystem = v; return nil },
+	"manufacturer":        func(b *OpenKitBuilder, v string) error { b.manufacturer = v; return nil },
+	"model_id":            func(b *OpenKitBuilder, v string) error { b.modelID = v; return nil },
+	"technology":          func(b *OpenKitBuilder, v string) error { b.technology = v; return nil },
+	"log_level": func(b *OpenKitBuilder, v string) error {
+		level, err := parseLogLevel(v)
+		if err == nil {
+			b.WithLogLevel(level)
+		}
+		return err
+	},
+	"data_collection_level": func(b *OpenKitBuilder, v string) error {
+		level, err := parseLevel(v, []string{"off", "performance", "user_behavior"})
+		b.dataCollectionLevel = configuration.DataCollectionLevel(level)
+		return err
+	},
+	"crash_reporting_level": func(b *OpenKitBuilder, v string) error {
+		level, err := parseLevel(v, []string{"off", "opt_out_crashes", "opt_in_crashes"})
+		b.crashReportLevel = configuration.CrashReportingLevel(level)
+		return err
+	},
+	"beacon_cache_max_record_age":        durationSetting(func(b *OpenKitBuilder) *time.Duration { return &b.beaconCacheMaxRecordAge }),
+	"beacon_cache_lower_memory_boundary": int64Setting(func(b *OpenKitBuilder) *int64 { return &b.beaconCacheLowerMemoryBoundary }),
+	"beacon_cache_upper_memory_boundary": int64Setting(func(b *OpenKitBuilder) *int64 { return &b.beaconCacheUpperMemoryBoundary }),
+	"beacon_cache_eviction_interval":     durationSetting(func(b *OpenKitBuilder) *time.Duration { return &b.beaconCacheEvictionInterval }),
+	"beacon_cache_directory":             func(b *OpenKitBuilder, v string) error { b.WithBeaconCacheDirectory(v); return nil },
+	"connect_timeout":                    durationSetting(func(b *OpenKitBuilder) *time.Duration { return &b.connectTimeout }),
+	"read_timeout":                       durationSetting(func(b *OpenKitBuilder) *time.Duration { return &b.readTimeout }),
+	"request_timeout":                    durationSetting(func(b *OpenKitBuilder) *time.Duration { return &b.requestTimeout }),
+	"retry_max_retries": func(b *OpenKitBuilder, v string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("not a number: %q", v)
+		}
+		b.retryPolicy.MaxRetries = n
+		return nil
+	},
+	"retry_base_backoff": durationSetting(func(b *OpenKitBuilder) *time.Duration { return &b.retryPolicy.BaseBackoff }),
+	"retry_max_backoff":  durationSetting(func(b *OpenKitBuilder) *time.Duration { return &b.retryPolicy.MaxBackoff }),
+	"retry_jitter": func(b *OpenKitBuilder, v string) error {
+		jitter, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return fmt.Errorf("not a number: %q", v)
+		}
+		b.retryPolicy.Jitter = jitter
+		return nil
+	},
+	"ca_certificates_file": func(b *OpenKitBuilder, v string) error { b.WithCACertificatesFile(v); return nil },
+	"retry_beacons": func(b *OpenKitBuilder, v string) error {
+		retryBeacons, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("not a boolean: %q", v)
+		}
+		b.retryPolicy.RetryBeacons = retryBeacons
+		return nil
+	},
+	"insecure_skip_verify": func(b *OpenKitBuilder, v string) error {
+		insecure, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("not a boolean: %q", v)
+		}
+		b.insecureSkipVerify = insecure
+		return nil
+	},
+	// both files are loaded together once all settings were applied
+	SETTING_CLIENT_CERTIFICATE_FILE: func(b *OpenKitBuilder, v string) error { return nil },
+	SETTING_CLIENT_KEY_FILE:         func(b *OpenKitBuilder, v string) error { return nil },
+}
+
+func durationSetting(field func(b *OpenKitBuilder) *time.Duration) builderSetting {
+	return func(b *OpenKitBuilder, v string) error {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("not a duration: %q", v)
+		}
+		*field(b) = d
+		return nil
+	}
+}
+
+func int64Setting(field func(b *OpenKitBuilder) *int64) builderSetting {
+	return func(b *OpenKitBuilder, v string) error {
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("not a number: %q", v)
+		}
+		*field(b) = n
+		return nil
+	}
+}
+
+// parseLevel accepts the level number or its name, names are the index in names
+func parseLevel(v string, names []string) (int, error) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	for i, name := range names {
+		if v == name || v == strconv.Itoa(i) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("must be one of %s", strings.Join(names, ", "))
+}
+
+func parseLogLevel(v string) (log.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "debug":
+		return log.DebugLevel, nil
+	case "info":
+		return log.InfoLevel, nil
+	case "warn", "warning":
+		return log.WarnLevel, nil
+	case "error":
+		return log.ErrorLevel, nil
+	}
+	return log.InfoLevel, fmt.Errorf("must be one of debug, info, warn, error")
+}
+
+// NewOpenKitBuilderFromSettings creates a builder from flat snake_case settings, like "endpoint_url" or
+// "beacon_cache_max_record_age". endpoint_url, application_id and device_id are required.
+// Unknown keys and invalid values are returned as ValidationErrors.
+func NewOpenKitBuilderFromSettings(settings map[string]string) (*OpenKitBuilder, error) {
+	var errs ValidationErrors
+
+	for _, key := range []string{SETTING_ENDPOINT_URL, SETTING_APPLICATION_ID, SETTING_DEVICE_ID} {
+		if _, ok := settings[key]; !ok {
+			errs = append(errs, &ValidationError{Field: key, Reason: "must be set"})
+		}
+	}
+
+	b := NewOpenKitBuilder("", "", 0).(*OpenKitBuilder)
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		setting, ok := builderSettings[key]
+		if !ok {
+			errs = append(errs, &ValidationError{Field: key, Reason: "unknown setting"})
+			continue
+		}
+		if err := setting(b, settings[key]); err != nil {
+			errs = append(errs, &ValidationError{Field: key, Reason: err.Error()})
+		}
+	}
+
+	certFile, hasCert := settings[SETTING_CLIENT_CERTIFICATE_FILE]
+	keyFile, hasKey := settings[SETTING_CLIENT_KEY_FILE]
+	if hasCert && hasKey {
+		b.WithClientCertificateFile(certFile, keyFile)
+	} else if hasCert || hasKey {
+		errs = append(errs, &ValidationError{Field: SETTING_CLIENT_CERTIFICATE_FILE, Reason: "client_certificate_file and client_key_file must be set together"})
+	}
+
+	errs = append(errs, b.errs...)
+	b.errs = nil
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return b, nil
+}
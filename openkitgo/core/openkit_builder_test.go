@@ -1,9 +1,28 @@
 package core
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestBuild(t *testing.T) {
@@ -14,3 +33,205 @@ func TestBuild(t *testing.T) {
 	assert.Equal(t, configuration.DATA_USER_BEHAVIOR, b.(*OpenKitBuilder).dataCollectionLevel)
 
 }
+
+func getWithBuilderTransport(b *OpenKitBuilder, url string) error {
+	client := &http.Client{Transport: b.buildTransport()}
+	resp, err := client.Get(url)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return err
+}
+
+func TestBuildTransportVerifiesCertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	b := NewOpenKitBuilder(server.URL, "app", 1).(*OpenKitBuilder)
+	assert.Error(t, getWithBuilderTransport(b, server.URL))
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	b = NewOpenKitBuilder(server.URL, "app", 1).WithCACertificates(caPEM).(*OpenKitBuilder)
+	assert.NoError(t, getWithBuilderTransport(b, server.URL))
+
+	b = NewOpenKitBuilder(server.URL, "app", 1).WithInsecureSkipVerify().(*OpenKitBuilder)
+	assert.NoError(t, getWithBuilderTransport(b, server.URL))
+
+	b = NewOpenKitBuilder(server.URL, "app", 1).WithTransport(nil).(*OpenKitBuilder)
+	assert.Error(t, getWithBuilderTransport(b, server.URL))
+}
+
+func TestBuildTransportPresentsClientCertificate(t *testing.T) {
+	certPEM, keyPEM := newTestCertificate(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	b := NewOpenKitBuilder(server.URL, "app", 1).WithInsecureSkipVerify().(*OpenKitBuilder)
+	assert.Error(t, getWithBuilderTransport(b, server.URL))
+
+	b = NewOpenKitBuilder(server.URL, "app", 1).
+		WithInsecureSkipVerify().
+		WithClientCertificate(certPEM, keyPEM).(*OpenKitBuilder)
+	assert.NoError(t, getWithBuilderTransport(b, server.URL))
+}
+
+func TestBuilderCollectsTLSErrors(t *testing.T) {
+	b := NewOpenKitBuilder("https://localhost", "app", 1).
+		WithCACertificates([]byte("not a certificate")).
+		WithCACertificatesFile("does-not-exist.pem").
+		WithClientCertificate([]byte("cert"), []byte("key")).(*OpenKitBuilder)
+
+	errs, ok := b.Validate().(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(errs))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder interfaces.OpenKitBuilder
+		fields  []string
+	}{
+		{"valid", NewOpenKitBuilder("https://tenant.live.dynatrace.com/mbeacon", "app", 1), nil},
+		{"empty application ID", NewOpenKitBuilder("https://localhost/mbeacon", " ", 1), []string{"applicationID"}},
+		{"unparsable URL", NewOpenKitBuilder("https://local host/%zz", "app", 1), []string{"endpointURL"}},
+		{"URL without scheme", NewOpenKitBuilder("localhost/mbeacon", "app", 1), []string{"endpointURL"}},
+		{"URL without host", NewOpenKitBuilder("https:///mbeacon", "app", 1), []string{"endpointURL"}},
+		{"lower cache bound above upper", NewOpenKitBuilder("https://localhost", "app", 1).
+			WithBeaconCacheLowerMemoryBoundary(200).
+			WithBeaconCacheUpperMemoryBoundary(100), []string{"beaconCacheLowerMemoryBoundary"}},
+		{"negative max record age", NewOpenKitBuilder("https://localhost", "app", 1).
+			WithBeaconCacheMaxRecordAge(-time.Minute), []string{"beaconCacheMaxRecordAge"}},
+		{"privacy levels", NewOpenKitBuilder("https://localhost", "app", 1).
+			WithDataCollectionLevel(configuration.DataCollectionLevel(3)).
+			WithCrashReportingLevel(configuration.CrashReportingLevel(-1)), []string{"dataCollectionLevel", "crashReportingLevel"}},
+		{"retry policy", NewOpenKitBuilder("https://localhost", "app", 1).
+			WithRetryPolicy(configuration.RetryPolicy{MaxRetries: -1, Jitter: 2}), []string{"retryPolicy", "retryPolicy"}},
+		{"aggregated", NewOpenKitBuilder("ftp://localhost", "", 1), []string{"endpointURL", "applicationID"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.builder.Validate()
+			if test.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var errs ValidationErrors
+			assert.True(t, errors.As(err, &errs))
+			var fields []string
+			for _, e := range errs {
+				fields = append(fields, e.Field)
+			}
+			assert.Equal(t, test.fields, fields)
+		})
+	}
+}
+
+func TestBuildEReturnsValidationErrors(t *testing.T) {
+	ok, err := NewOpenKitBuilder("https://localhost", "", 1).BuildE()
+	assert.Nil(t, ok)
+	assert.Error(t, err)
+}
+
+func TestBuildERejectsUnusableBeaconCacheDirectory(t *testing.T) {
+	file, err := ioutil.TempFile("", "openkit")
+	assert.NoError(t, err)
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// a directory below a regular file cannot be created
+	ok, err := NewOpenKitBuilder("https://localhost", "app", 1).
+		WithBeaconCacheDirectory(filepath.Join(file.Name(), "cache")).
+		BuildE()
+	assert.Nil(t, ok)
+	var errs ValidationErrors
+	if assert.True(t, errors.As(err, &errs)) {
+		assert.Equal(t, "beaconCacheDirectory", errs[0].Field)
+	}
+
+	// a beacon cache passed to the builder is used instead of the directory
+	assert.NoError(t, NewOpenKitBuilder("https://localhost", "app", 1).
+		WithBeaconCacheDirectory(filepath.Join(file.Name(), "cache")).
+		WithBeaconCache(caching.NewBeaconCache(logger)).
+		Validate())
+}
+
+func newTestCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "openkit-test-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+type countingRoundTripper struct {
+	requests int32
+}
+
+func (c *countingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&c.requests, 1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestBuilderUsesRoundTripper(t *testing.T) {
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		w.Write([]byte(testStatusResponse))
+	}))
+	defer server.Close()
+
+	rt := &countingRoundTripper{}
+	ok := NewOpenKitBuilder(server.URL, "app", 1).WithRoundTripper(rt).Build()
+	defer ok.Shutdown()
+	assert.True(t, ok.WaitForInitCompletionTimeout(5*time.Second))
+
+	ok.CreateSession("127.0.0.1").EnterAction("action").LeaveAction()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, ok.Flush(ctx))
+
+	assert.True(t, atomic.LoadInt32(&received) >= 2)
+	assert.Equal(t, atomic.LoadInt32(&received), atomic.LoadInt32(&rt.requests))
+}
+
+func TestBuilderSharesHTTPClient(t *testing.T) {
+	client := &http.Client{}
+	ok := NewOpenKitBuilder("https://localhost", "app", 1).WithHTTPClient(client).Build().(*OpenKit)
+	defer ok.Shutdown()
+
+	httpClient := ok.beaconSender.context.getHttpClient()
+	assert.True(t, client == httpClient.client)
+	assert.True(t, httpClient.client == ok.beaconSender.context.getHttpClient().client)
+}
+
+func TestBuilderUsesBeaconCache(t *testing.T) {
+	cache := caching.NewBeaconCache(logger)
+	builder := NewOpenKitBuilder("https://localhost", "app", 1).
+		WithBeaconCache(cache).
+		WithBeaconCacheDirectory(t.TempDir())
+	ok := NewOpenKit(builder.(*OpenKitBuilder)).(*OpenKit)
+
+	// the cache is wrapped to notify the evictor, data still ends up in it
+	key := caching.NewBeaconKey(1, 0)
+	ok.beaconCache.AddEventData(key, time.Now(), "et=18")
+	assert.False(t, cache.IsEmpty(key))
+}
@@ -0,0 +1,311 @@
+package core
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testStatusResponse = `{"appConfig":{"capture":1,"trafficControlPercentage":100}}`
+
+func newTestOpenKit(url string) *OpenKit {
+	return NewOpenKitBuilder(url, "98972aef-02ac-4ecb-be1e-a6698af2de60", 1).
+		WithLogLevel(log.WarnLevel).
+		WithRetryPolicy(configuration.RetryPolicy{MaxRetries: 1, BaseBackoff: time.Millisecond}).
+		Build().(*OpenKit)
+}
+
+func TestShutdownContextFlushesSessions(t *testing.T) {
+	var mutex sync.Mutex
+	var beacons []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			body, _ := gzip.NewReader(r.Body)
+			data, _ := ioutil.ReadAll(body)
+			mutex.Lock()
+			beacons = append(beacons, string(data))
+			mutex.Unlock()
+		}
+		w.Write([]byte(testStatusResponse))
+	}))
+	defer server.Close()
+
+	ok := newTestOpenKit(server.URL)
+	assert.True(t, ok.WaitForInitCompletionTimeout(5*time.Second))
+	session := ok.CreateSession("127.0.0.1")
+	session.EnterAction("shutdownAction").LeaveAction()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, ok.ShutdownContext(ctx))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.True(t, strings.Contains(strings.Join(beacons, "\n"), "shutdownAction"))
+
+	// A second call returns immediately
+	assert.NoError(t, ok.ShutdownContext(context.Background()))
+}
+
+func TestShutdownContextReturnsErrorOnTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			<-release
+		}
+		w.Write([]byte(testStatusResponse))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ok := newTestOpenKit(server.URL)
+	assert.True(t, ok.WaitForInitCompletionTimeout(5*time.Second))
+	session := ok.CreateSession("127.0.0.1")
+	session.EnterAction("pendingAction").LeaveAction()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := ok.ShutdownContext(ctx)
+
+	var shutdownErr *ShutdownError
+	assert.True(t, errors.As(err, &shutdownErr))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, shutdownErr.UnsentSessions)
+}
+
+func TestShutdownDoesNotWaitForTheFinalFlush(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			<-release
+		}
+		w.Write([]byte(testStatusResponse))
+	}))
+	defer server.Close()
+
+	ok := newTestOpenKit(server.URL)
+	assert.True(t, ok.WaitForInitCompletionTimeout(5*time.Second))
+	session := ok.CreateSession("127.0.0.1")
+	session.EnterAction("pendingAction").LeaveAction()
+
+	returned := make(chan struct{})
+	go func() {
+		ok.Shutdown()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown waited for the beacon sender")
+	}
+
+	// ShutdownContext still waits for the flush started by Shutdown
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, ok.ShutdownContext(ctx))
+}
+
+func TestFlushSendsOpenSessions(t *testing.T) {
+	var mutex sync.Mutex
+	var beacons []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			body, _ := gzip.NewReader(r.Body)
+			data, _ := ioutil.ReadAll(body)
+			mutex.Lock()
+			beacons = append(beacons, string(data))
+			mutex.Unlock()
+		}
+		w.Write([]byte(testStatusResponse))
+	}))
+	defer server.Close()
+
+	ok := newTestOpenKit(server.URL)
+	defer ok.Shutdown()
+	assert.True(t, ok.WaitForInitCompletionTimeout(5*time.Second))
+
+	session := ok.CreateSession("127.0.0.1")
+	session.EnterAction("flushAction").LeaveAction()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, ok.Flush(ctx))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 1, len(beacons))
+	assert.True(t, strings.Contains(beacons[0], "na=flushAction"))
+	// the session is still open
+	assert.False(t, strings.Contains(beacons[0], "et=19"))
+}
+
+func TestFlushReturnsLastFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(testStatusResponse))
+	}))
+	defer server.Close()
+
+	ok := newTestOpenKit(server.URL)
+	defer ok.Shutdown()
+	assert.True(t, ok.WaitForInitCompletionTimeout(5*time.Second))
+
+	session := ok.CreateSession("127.0.0.1")
+	session.EnterAction("flushAction").LeaveAction()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := ok.Flush(ctx)
+
+	var flushErr *FlushError
+	assert.True(t, errors.As(err, &flushErr))
+	assert.Equal(t, http.StatusServiceUnavailable, flushErr.ResponseCode)
+}
+
+func TestFlushReportsEveryFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			body, _ := gzip.NewReader(r.Body)
+			data, _ := ioutil.ReadAll(body)
+			if strings.Contains(string(data), "na=failing") {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Write([]byte(testStatusResponse))
+	}))
+	defer server.Close()
+
+	ok := newTestOpenKit(server.URL)
+	defer ok.Shutdown()
+	assert.True(t, ok.WaitForInitCompletionTimeout(5*time.Second))
+
+	// the session that fails is sent first, the last response is a success
+	ok.CreateSession("127.0.0.1").EnterAction("failing").LeaveAction()
+	ok.CreateSession("127.0.0.1").EnterAction("working").LeaveAction()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := ok.Flush(ctx)
+
+	var flushErr *FlushError
+	assert.True(t, errors.As(err, &flushErr))
+	assert.Equal(t, http.StatusServiceUnavailable, flushErr.ResponseCode)
+	assert.Equal(t, 1, flushErr.FailedRequests)
+}
+
+func TestFlushReturnsWhileBackendIsUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ok := newTestOpenKit(server.URL)
+	defer ok.Shutdown()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- ok.Flush(context.Background())
+	}()
+
+	select {
+	case err := <-result:
+		assert.Equal(t, ErrNotInitialized, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush blocked while the sender could not initialize")
+	}
+}
+
+func TestFlushAfterShutdown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testStatusResponse))
+	}))
+	defer server.Close()
+
+	ok := newTestOpenKit(server.URL)
+	ok.Shutdown()
+	assert.Equal(t, ErrShutDown, ok.Flush(context.Background()))
+}
+
+func TestSessionNumbersAreReproducibleWithSeededSource(t *testing.T) {
+	newOpenKit := func() *OpenKit {
+		builder := NewOpenKitBuilder("https://localhost:9999/mbeacon", "app", 1).
+			WithLogLevel(log.WarnLevel).
+			WithRandomSource(rand.NewSource(7))
+		return NewOpenKit(builder.(*OpenKitBuilder)).(*OpenKit)
+	}
+	ok1 := newOpenKit()
+	ok2 := newOpenKit()
+
+	s1 := ok1.CreateSession("").(*SessionProxy)
+	s2 := ok2.CreateSession("").(*SessionProxy)
+	assert.Equal(t, s1.currentSession.beacon.key, s2.currentSession.beacon.key)
+	assert.Equal(t, s1.currentSession.beacon.trafficControlValue, s2.currentSession.beacon.trafficControlValue)
+
+	other := ok1.CreateSession("").(*SessionProxy)
+	assert.Equal(t, s1.currentSession.beacon.key.BeaconId+1, other.currentSession.beacon.key.BeaconId)
+}
+
+func TestSplitSessionsKeepSessionNumber(t *testing.T) {
+	builder := NewOpenKitBuilder("https://localhost:9999/mbeacon", "app", 1).WithLogLevel(log.WarnLevel)
+	ok := NewOpenKit(builder.(*OpenKitBuilder)).(*OpenKit)
+
+	proxy := ok.CreateSession("").(*SessionProxy)
+	first := proxy.currentSession.beacon.key
+
+	proxy.createSplitSessionAndMakeCurrent(nil, ok.clock.Now())
+	second := proxy.currentSession.beacon.key
+
+	assert.Equal(t, first.BeaconId, second.BeaconId)
+	assert.Equal(t, first.BeaconSeqNo+1, second.BeaconSeqNo)
+}
+
+func TestCreateSessionWithOptions(t *testing.T) {
+	builder := NewOpenKitBuilder("https://localhost:9999/mbeacon", "app", 1).
+		WithLogLevel(log.WarnLevel).
+		WithDataCollectionLevel(configuration.DATA_PERFORMANCE)
+	ok := NewOpenKit(builder.(*OpenKitBuilder)).(*OpenKit)
+
+	deviceID := int64(42)
+	level := configuration.DATA_USER_BEHAVIOR
+	proxy := ok.CreateSessionWithOptions(interfaces.SessionOptions{
+		ClientIP:            "10.0.0.1",
+		DeviceID:            &deviceID,
+		UserTag:             "someone@example.com",
+		Attributes:          map[string]string{"tenant": "acme"},
+		DataCollectionLevel: &level,
+	}).(*SessionProxy)
+	other := ok.CreateSession("10.0.0.2").(*SessionProxy)
+
+	assert.Equal(t, int64(42), proxy.currentSession.beacon.deviceID)
+	assert.Equal(t, configuration.DATA_USER_BEHAVIOR, proxy.privacyConfiguration.GetDataCollectionLevel())
+	assert.Equal(t, int64(1), other.currentSession.beacon.deviceID)
+	assert.Equal(t, configuration.DATA_PERFORMANCE, other.privacyConfiguration.GetDataCollectionLevel())
+	assert.Equal(t, int64(1), ok.openKitConfiguration.DeviceID)
+	assert.Equal(t, configuration.DATA_PERFORMANCE, ok.privacyConfiguration.GetDataCollectionLevel())
+
+	beacon := proxy.currentSession.beacon
+	beacon.cache.PrepareDataForSending(beacon.key)
+	data := beacon.cache.GetNextBeaconChunk(beacon.key, "", 10000, '&')
+	assert.Contains(t, data, "someone%40example.com")
+	assert.Contains(t, data, "tenant")
+	assert.Contains(t, data, "acme")
+}
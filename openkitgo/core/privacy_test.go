@@ -0,0 +1,142 @@
+package core
+
+import (
+	"fmt"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func newPrivacyTestOpenKit() *OpenKit {
+	builder := NewOpenKitBuilder("https://localhost:9999/mbeacon", "app", 42).WithLogLevel(log.WarnLevel)
+	return NewOpenKit(builder.(*OpenKitBuilder)).(*OpenKit)
+}
+
+// cachedEventTypes returns the event types of all records cached for the current session of proxy
+func cachedEventTypes(proxy *SessionProxy) []EventType {
+	beacon := proxy.currentSession.beacon
+	beacon.cache.PrepareDataForSending(beacon.key)
+	defer beacon.cache.ResetChunkedData(beacon.key)
+
+	var eventTypes []EventType
+	for _, record := range strings.Split(beacon.cache.GetNextBeaconChunk(beacon.key, "", 100000, BEACON_DATA_DELIMITER), "&et=") {
+		if record != "" {
+			eventTypes = append(eventTypes, eventTypeOf("et="+record))
+		}
+	}
+	return eventTypes
+}
+
+func TestLoweringDataCollectionLevelPurgesCache(t *testing.T) {
+	ok := newPrivacyTestOpenKit()
+	proxy := ok.CreateSession("").(*SessionProxy)
+
+	proxy.IdentifyUser("someone@example.com")
+	proxy.EnterAction("action").ReportValue("value", 1).ReportEvent("event").LeaveAction()
+	proxy.currentSession.ReportCrash("crash", "reason", "stacktrace")
+
+	assert.ElementsMatch(t, []EventType{SESSION_START, IDENTIFY_USER, VALUE_INT, NAMED_EVENT, CRASH, ACTION}, cachedEventTypes(proxy))
+
+	proxy.SetDataCollectionLevel(configuration.DATA_PERFORMANCE)
+	assert.ElementsMatch(t, []EventType{SESSION_START, CRASH, ACTION}, cachedEventTypes(proxy))
+
+	proxy.SetCrashReportingLevel(configuration.CRASH_OPT_OUT_CRASHES)
+	assert.ElementsMatch(t, []EventType{SESSION_START, ACTION}, cachedEventTypes(proxy))
+
+	proxy.SetDataCollectionLevel(configuration.DATA_OFF)
+	assert.Empty(t, cachedEventTypes(proxy))
+}
+
+func TestBeaconDataUsesCurrentPrivacyLevels(t *testing.T) {
+	ok := newPrivacyTestOpenKit()
+	proxy := ok.CreateSession("").(*SessionProxy)
+	beacon := proxy.currentSession.beacon
+
+	data := beacon.createImmutableBasicBeaconData()
+	assert.Contains(t, data, "&vi=42&")
+	assert.Contains(t, data, "&dl=2&cl=2")
+
+	proxy.SetDataCollectionLevel(configuration.DATA_PERFORMANCE)
+	proxy.SetCrashReportingLevel(configuration.CRASH_OFF)
+
+	data = beacon.createImmutableBasicBeaconData()
+	assert.NotContains(t, data, "&vi=42&")
+	assert.Contains(t, data, "&sn=1&")
+	assert.Contains(t, data, "&dl=1&cl=0")
+}
+
+func TestWebRequestTagUsesCurrentPrivacyLevels(t *testing.T) {
+	ok := newPrivacyTestOpenKit()
+	proxy := ok.CreateSession("").(*SessionProxy)
+	beacon := proxy.currentSession.beacon
+
+	assert.Contains(t, beacon.CreateTag(0, 1), "_42_")
+
+	// the tag is sent to third party servers, it carries the same device ID as the beacon data
+	proxy.SetDataCollectionLevel(configuration.DATA_PERFORMANCE)
+	tag := beacon.CreateTag(0, 1)
+	assert.NotEmpty(t, tag)
+	assert.NotContains(t, tag, "_42_")
+	assert.Contains(t, tag, fmt.Sprintf("_%d_", beacon.randomDeviceID))
+}
+
+func TestOpenKitPrivacyLevelsApplyToNewSessions(t *testing.T) {
+	ok := newPrivacyTestOpenKit()
+	before := ok.CreateSession("").(*SessionProxy)
+
+	ok.SetDataCollectionLevel(configuration.DATA_OFF)
+	ok.SetCrashReportingLevel(configuration.CRASH_OFF)
+	after := ok.CreateSession("").(*SessionProxy)
+
+	assert.Equal(t, configuration.DATA_USER_BEHAVIOR, before.privacyConfiguration.GetDataCollectionLevel())
+	assert.Equal(t, configuration.CRASH_OPT_IN_CRASHES, before.privacyConfiguration.GetCrashReportingLevel())
+	assert.Equal(t, configuration.DATA_OFF, after.privacyConfiguration.GetDataCollectionLevel())
+	assert.Equal(t, configuration.CRASH_OFF, after.privacyConfiguration.GetCrashReportingLevel())
+}
+
+func TestBeaconHonoursPrivacyMatrix(t *testing.T) {
+	byDataCollectionLevel := map[configuration.DataCollectionLevel][]EventType{
+		configuration.DATA_OFF:           {},
+		configuration.DATA_PERFORMANCE:   {SESSION_START, SESSION_END, ACTION, EXCEPTION, WEB_REQUEST},
+		configuration.DATA_USER_BEHAVIOR: {SESSION_START, SESSION_END, ACTION, EXCEPTION, WEB_REQUEST, NAMED_EVENT, VALUE_STRING, VALUE_INT, VALUE_DOUBLE, IDENTIFY_USER},
+	}
+	byCrashReportingLevel := map[configuration.CrashReportingLevel][]EventType{
+		configuration.CRASH_OFF:             {},
+		configuration.CRASH_OPT_OUT_CRASHES: {},
+		configuration.CRASH_OPT_IN_CRASHES:  {CRASH},
+	}
+
+	for dataCollectionLevel, allowedData := range byDataCollectionLevel {
+		for crashReportingLevel, allowedCrashes := range byCrashReportingLevel {
+			builder := NewOpenKitBuilder("https://localhost:9999/mbeacon", "app", 42).
+				WithLogLevel(log.WarnLevel).
+				WithDataCollectionLevel(dataCollectionLevel).
+				WithCrashReportingLevel(crashReportingLevel)
+			ok := NewOpenKit(builder.(*OpenKitBuilder)).(*OpenKit)
+			proxy := ok.CreateSession("").(*SessionProxy)
+			session := proxy.currentSession
+			beacon := session.beacon
+			now := ok.clock.Now()
+
+			action := NewAction(logger, session, nil, "action", beacon, now)
+			beacon.AddActionAt(action, now)
+			beacon.reportEvent(int(action.id), "event", now)
+			beacon.reportValue(int(action.id), "string", "value", now)
+			beacon.reportValue(int(action.id), "int", 1, now)
+			beacon.reportValue(int(action.id), "double", 1.5, now)
+			beacon.reportError(int(action.id), "error", "cause", "description", "stacktrace", now)
+			NewWebRequestTracer(logger, session, "https://example.com", beacon, now).StopAt(200, now)
+			beacon.identifyUser("someone@example.com", now)
+			beacon.reportCrash("crash", "reason", "stacktrace", now)
+			beacon.EndSessionAt(now)
+
+			var allowed []EventType
+			allowed = append(allowed, allowedData...)
+			allowed = append(allowed, allowedCrashes...)
+			assert.ElementsMatch(t, allowed, cachedEventTypes(proxy), "dl=%d cl=%d", dataCollectionLevel, crashReportingLevel)
+			assert.Equal(t, dataCollectionLevel != configuration.DATA_OFF, beacon.CreateTag(0, 1) != "", "dl=%d", dataCollectionLevel)
+		}
+	}
+}
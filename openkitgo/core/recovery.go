@@ -0,0 +1,116 @@
+package core
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Keys of the metadata a Beacon stores with its records, persistent caches need them to send the records
+// after a restart
+const (
+	METADATA_KEY_DEVICE_ID             = "di"
+	METADATA_KEY_RANDOM_DEVICE_ID      = "rd"
+	METADATA_KEY_CLIENT_IP_ADDRESS     = "ip"
+	METADATA_KEY_SESSION_START_TIME    = "st"
+	METADATA_KEY_TRAFFIC_CONTROL_VALUE = "tc"
+	METADATA_KEY_DATA_COLLECTION_LEVEL = "dl"
+	METADATA_KEY_CRASH_REPORTING_LEVEL = "cl"
+)
+
+func (b *Beacon) createMetadata() string {
+	privacyConfig := b.configuration.PrivacyConfiguration
+
+	metadata := url.Values{}
+	metadata.Set(METADATA_KEY_DEVICE_ID, strconv.FormatInt(b.deviceID, 10))
+	metadata.Set(METADATA_KEY_RANDOM_DEVICE_ID, strconv.FormatInt(b.randomDeviceID, 10))
+	metadata.Set(METADATA_KEY_CLIENT_IP_ADDRESS, b.clientIPAddress)
+	metadata.Set(METADATA_KEY_SESSION_START_TIME, strconv.FormatInt(b.sessionStartTime.UnixNano(), 10))
+	metadata.Set(METADATA_KEY_TRAFFIC_CONTROL_VALUE, strconv.Itoa(b.trafficControlValue))
+	metadata.Set(METADATA_KEY_DATA_COLLECTION_LEVEL, strconv.Itoa(int(privacyConfig.GetDataCollectionLevel())))
+	metadata.Set(METADATA_KEY_CRASH_REPORTING_LEVEL, strconv.Itoa(int(privacyConfig.GetCrashReportingLevel())))
+	return metadata.Encode()
+}
+
+// recoverSessions hands the data a previous process left in a persistent cache to the beacon sender.
+// It is sent like the data of finished sessions, without a session end event. New sessions do not get the
+// session numbers of recovered ones, so they cannot share, overwrite or delete their cache entries.
+func (o *OpenKit) recoverSessions() {
+	var recovered []int32
+	for _, key := range o.beaconCache.GetBeaconKeys() {
+		if o.beaconCache.IsEmpty(key) {
+			o.beaconCache.DeleteCacheEntry(key)
+			continue
+		}
+
+		beacon, err := o.newRecoveredBeacon(key, caching.GetMetadata(o.beaconCache, key))
+		if err != nil {
+			o.log.WithFields(log.Fields{"key": key.String(), "error": err}).Warning("dropping persisted data without valid metadata")
+			o.beaconCache.DeleteCacheEntry(key)
+			continue
+		}
+
+		session := &Session{
+			log:               o.log,
+			beacon:            beacon,
+			remainingRequests: MAX_NEW_SESSION_REQUESTS,
+		}
+		session.State = NewSessionState(session)
+		session.State.MarkAsIsFinishing()
+		session.State.MarkAsFinished()
+
+		recovered = append(recovered, key.BeaconId)
+		o.log.WithFields(log.Fields{"key": key.String()}).Info("recovered persisted session")
+		o.beaconSender.AddSession(session)
+	}
+
+	if len(recovered) > 0 {
+		o.sessionIDProvider = providers.NewSkippingSessionIDProvider(o.sessionIDProvider, recovered)
+	}
+}
+
+func (o *OpenKit) newRecoveredBeacon(key caching.BeaconKey, metadata string) (*Beacon, error) {
+	values, err := url.ParseQuery(metadata)
+	if err != nil {
+		return nil, err
+	}
+	var parseErr error
+	parseInt := func(key string) int64 {
+		n, err := strconv.ParseInt(values.Get(key), 10, 64)
+		if err != nil && parseErr == nil {
+			parseErr = err
+		}
+		return n
+	}
+
+	deviceID := parseInt(METADATA_KEY_DEVICE_ID)
+	randomDeviceID := parseInt(METADATA_KEY_RANDOM_DEVICE_ID)
+	sessionStartTime := time.Unix(0, parseInt(METADATA_KEY_SESSION_START_TIME))
+	trafficControlValue := int(parseInt(METADATA_KEY_TRAFFIC_CONTROL_VALUE))
+	privacyConfig := configuration.NewPrivacyConfiguration(
+		configuration.DataCollectionLevel(parseInt(METADATA_KEY_DATA_COLLECTION_LEVEL)),
+		configuration.CrashReportingLevel(parseInt(METADATA_KEY_CRASH_REPORTING_LEVEL)),
+	)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	return &Beacon{
+		key:                 key,
+		sessionStartTime:    sessionStartTime,
+		deviceID:            deviceID,
+		clientIPAddress:     values.Get(METADATA_KEY_CLIENT_IP_ADDRESS),
+		randomDeviceID:      randomDeviceID,
+		configuration:       configuration.NewBeaconConfiguration(o.openKitConfiguration, privacyConfig, o.beaconSender.GetCurrentServerId()),
+		trafficControlValue: trafficControlValue,
+		log:                 o.log,
+		cache:               o.beaconCache,
+		sessionIDProvider:   o.sessionIDProvider,
+		threadIDProvider:    o.threadIDProvider,
+		clock:               o.clock,
+	}, nil
+}
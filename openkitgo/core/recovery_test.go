@@ -0,0 +1,103 @@
+package core
+
+import (
+	"compress/gzip"
+	"context"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPersistedSessionsAreSentAfterRestart(t *testing.T) {
+	var mutex sync.Mutex
+	var beacons []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			body, _ := gzip.NewReader(r.Body)
+			data, _ := ioutil.ReadAll(body)
+			mutex.Lock()
+			beacons = append(beacons, string(data))
+			mutex.Unlock()
+		}
+		w.Write([]byte(testStatusResponse))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	newBuilder := func() *OpenKitBuilder {
+		return NewOpenKitBuilder(server.URL, "app", 7).
+			WithLogLevel(log.WarnLevel).
+			WithRetryPolicy(configuration.RetryPolicy{MaxRetries: 1, BaseBackoff: time.Millisecond}).
+			WithBeaconCacheDirectory(dir).(*OpenKitBuilder)
+	}
+
+	// the first process never starts sending, like one that crashed
+	crashed := NewOpenKit(newBuilder()).(*OpenKit)
+	session := crashed.CreateSession("10.0.0.1")
+	session.EnterAction("beforeCrash").LeaveAction()
+	session.ReportCrash("panic", "nil pointer", "main.go:42")
+
+	assert.Equal(t, 1, NewOpenKit(newBuilder()).(*OpenKit).beaconSender.getSessionCount())
+
+	ok := newBuilder().Build().(*OpenKit)
+	assert.True(t, ok.WaitForInitCompletionTimeout(5*time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, ok.ShutdownContext(ctx))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	sent := strings.Join(beacons, "\n")
+	assert.Contains(t, sent, "beforeCrash")
+	assert.Contains(t, sent, "nil+pointer")
+	assert.Contains(t, sent, "&vi=7&")
+	assert.Contains(t, sent, "&ip=10.0.0.1&")
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(t, files)
+}
+
+func TestNewSessionsDoNotReuseRecoveredSessionNumbers(t *testing.T) {
+	dir := t.TempDir()
+	newOpenKit := func() *OpenKit {
+		// every process hands out the same session numbers
+		builder := NewOpenKitBuilder("https://localhost:9999/mbeacon", "app", 7).
+			WithLogLevel(log.WarnLevel).
+			WithSessionIDProvider(providers.NewSessionIDProvider(providers.NewRandomNumberGenerator(rand.NewSource(1)))).
+			WithBeaconCacheDirectory(dir)
+		return NewOpenKit(builder.(*OpenKitBuilder)).(*OpenKit)
+	}
+
+	crashed := newOpenKit()
+	crashed.CreateSession("10.0.0.1").EnterAction("beforeCrash").LeaveAction()
+	recoveredKey := crashed.beaconCache.GetBeaconKeys()[0]
+
+	ok := newOpenKit()
+	metadata := caching.GetMetadata(ok.beaconCache, recoveredKey)
+	session := ok.CreateSession("10.0.0.2").(*SessionProxy)
+	session.EnterAction("afterRestart").LeaveAction()
+
+	newKey := session.currentSession.beacon.key
+	assert.NotEqual(t, recoveredKey.BeaconId, newKey.BeaconId)
+	assert.Equal(t, metadata, caching.GetMetadata(ok.beaconCache, recoveredKey))
+	assert.Equal(t, []caching.BeaconKey{recoveredKey, newKey}, sortedKeys(ok.beaconCache.GetBeaconKeys()))
+}
+
+func sortedKeys(keys []caching.BeaconKey) []caching.BeaconKey {
+	sort.Slice(keys, func(i, j int) bool { return keys[i].BeaconId < keys[j].BeaconId })
+	return keys
+}
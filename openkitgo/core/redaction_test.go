@@ -0,0 +1,40 @@
+package core
+
+import (
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/redaction"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRedactionBeforeCaching(t *testing.T) {
+	redactor := redaction.NewDefaultRedactor()
+	builder := NewOpenKitBuilder("https://localhost:9999/mbeacon", "app", 42).
+		WithLogLevel(log.WarnLevel).
+		WithRedactor(redactor)
+	ok := NewOpenKit(builder.(*OpenKitBuilder)).(*OpenKit)
+
+	proxy := ok.CreateSession("192.168.15.103").(*SessionProxy)
+	proxy.IdentifyUser("someone@example.com")
+	action := proxy.EnterAction("Reset password for someone@example.com")
+	action.ReportValue("email", "someone@example.com")
+	action.ReportError("login failed", "HttpError", "Authorization: Bearer abc", "")
+	action.TraceWebRequest("https://example.com/login?token=abc&page=2").Stop(200)
+	action.LeaveAction()
+
+	beacon := proxy.currentSession.beacon
+	beacon.cache.PrepareDataForSending(beacon.key)
+	data := beacon.cache.GetNextBeaconChunk(beacon.key, "", 100000, BEACON_DATA_DELIMITER)
+
+	assert.NotContains(t, data, "example.com%2Flogin%3Ftoken%3Dabc")
+	assert.NotContains(t, data, "someone%40example.com")
+	assert.NotContains(t, data, "Bearer+abc")
+	assert.Contains(t, data, "page%3D2")
+	assert.Equal(t, "192.168.15.0", beacon.clientIPAddress)
+
+	stats := redactor.Stats()
+	assert.Equal(t, int64(1), stats.ClientIPsAnonymized)
+	assert.Equal(t, int64(1), stats.Redactions[redaction.FIELD_USER_TAG])
+	assert.Equal(t, int64(1), stats.Redactions[redaction.FIELD_URL])
+	assert.Equal(t, int64(1), stats.Redactions[redaction.FIELD_VALUE])
+}
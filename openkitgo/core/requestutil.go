@@ -3,34 +3,25 @@ package core
 import (
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/protocol"
 	"net/http"
-	"time"
 )
 
 type BeaconSendingRequestUtil struct{}
 
-func sendStatusRequest(ctx *BeaconSendingContext, numRetries int, initialRetryDelay time.Duration) protocol.StatusResponse {
-
-	var statusResponse protocol.StatusResponse
-
-	sleepTime := initialRetryDelay
-	retry := 0
-
+// sendStatusRequest sends a status request and retries it on connection errors and error responses other than 429,
+// which asks the states to back off. The number of retries and the backoff come from the retry policy.
+func sendStatusRequest(ctx *BeaconSendingContext) protocol.StatusResponse {
 	httpClient := ctx.getHttpClient()
-	for {
-		statusResponse = httpClient.SendStatusRequest(ctx)
+	for retry := 0; ; retry++ {
+		statusResponse := httpClient.SendStatusRequest(ctx)
 		if statusResponse.ResponseCode < http.StatusBadRequest ||
-			statusResponse.ResponseCode == 429 ||
-			retry >= numRetries ||
+			statusResponse.ResponseCode == http.StatusTooManyRequests ||
+			retry >= httpClient.retryPolicy.MaxRetries ||
 			ctx.IsShutdownRequested() {
 
 			// If we get here, stop trying
 			// Everything either worked, or someone else asked us to stop
-			break
+			return statusResponse
 		}
-		time.Sleep(sleepTime)
-		sleepTime *= 2
-		retry++
-
+		ctx.sleep(httpClient.retryBackoff(retry))
 	}
-	return statusResponse
 }
@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/protocol"
-	log "github.com/sirupsen/logrus"
 	"sync"
 	"time"
 )
@@ -15,7 +15,7 @@ const (
 )
 
 type Session struct {
-	log                             *log.Logger
+	log                             log.Logger
 	parent                          OpenKitComposite
 	beacon                          *Beacon
 	State                           *SessionState
@@ -26,7 +26,7 @@ type Session struct {
 }
 
 func (s *Session) EnterAction(actionName string) interfaces.Action {
-	return s.EnterActionAt(actionName, time.Now())
+	return s.EnterActionAt(actionName, s.beacon.clock.Now())
 }
 
 func (s *Session) EnterActionAt(actionName string, timestamp time.Time) interfaces.Action {
@@ -41,7 +41,7 @@ func (s *Session) EnterActionAt(actionName string, timestamp time.Time) interfac
 }
 
 func (s *Session) IdentifyUser(userTag string) {
-	s.IdentifyUserAt(userTag, time.Now())
+	s.IdentifyUserAt(userTag, s.beacon.clock.Now())
 }
 
 func (s *Session) IdentifyUserAt(userTag string, timestamp time.Time) {
@@ -52,8 +52,29 @@ func (s *Session) IdentifyUserAt(userTag string, timestamp time.Time) {
 	}
 }
 
+// SetDataCollectionLevel changes the privacy configuration shared with the SessionProxy of this session
+func (s *Session) SetDataCollectionLevel(level configuration.DataCollectionLevel) {
+	s.log.WithFields(log.Fields{"session": s, "level": level}).Debug("Session.SetDataCollectionLevel()")
+	s.beacon.configuration.PrivacyConfiguration.SetDataCollectionLevel(level)
+	s.beacon.purgeDisallowedData()
+}
+
+// SetCrashReportingLevel changes the privacy configuration shared with the SessionProxy of this session
+func (s *Session) SetCrashReportingLevel(level configuration.CrashReportingLevel) {
+	s.log.WithFields(log.Fields{"session": s, "level": level}).Debug("Session.SetCrashReportingLevel()")
+	s.beacon.configuration.PrivacyConfiguration.SetCrashReportingLevel(level)
+	s.beacon.purgeDisallowedData()
+}
+
+// reportAttribute reports a value that belongs to the session itself and not to an action
+func (s *Session) reportAttribute(key string, value string, timestamp time.Time) {
+	if !s.State.IsFinishingOrFinished() {
+		s.beacon.reportValue(0, key, value, timestamp)
+	}
+}
+
 func (s *Session) ReportCrash(errorName string, reason string, stacktrace string) {
-	s.ReportCrashAt(errorName, reason, stacktrace, time.Now())
+	s.ReportCrashAt(errorName, reason, stacktrace, s.beacon.clock.Now())
 }
 
 func (s *Session) ReportCrashAt(errorName string, reason string, stacktrace string, timestamp time.Time) {
@@ -68,7 +89,7 @@ func (s *Session) String() string {
 	return fmt.Sprintf("Session(%d)", s.beacon.GetSessionNumber())
 }
 
-func NewSession(log *log.Logger, parent OpenKitComposite, beacon *Beacon, timestamp time.Time) *Session {
+func NewSession(log log.Logger, parent OpenKitComposite, beacon *Beacon, timestamp time.Time) *Session {
 
 	s := &Session{
 		log:               log,
@@ -90,7 +111,7 @@ func (s *Session) onChildClosed(child OpenKitObject) {
 	s.removeChildFromList(child)
 
 	if s.State.WasTriedForEnding() && s.getChildCount() == 0 {
-		s.endWithEvent(false, time.Now())
+		s.endWithEvent(false, s.beacon.clock.Now())
 	}
 
 }
@@ -128,7 +149,7 @@ func (s *Session) getActionID() int {
 }
 
 func (s *Session) close() {
-	s.closeAt(time.Now())
+	s.closeAt(s.beacon.clock.Now())
 }
 
 func (s *Session) closeAt(timestamp time.Time) {
@@ -136,7 +157,7 @@ func (s *Session) closeAt(timestamp time.Time) {
 }
 
 func (s *Session) End() {
-	s.EndAt(time.Now())
+	s.EndAt(s.beacon.clock.Now())
 }
 
 func (s *Session) EndAt(timestamp time.Time) {
@@ -194,7 +215,7 @@ func (s *Session) isEmpty() bool {
 }
 
 func (s *Session) TraceWebRequest(url string) interfaces.WebRequestTracer {
-	return s.TraceWebRequestAt(url, time.Now())
+	return s.TraceWebRequestAt(url, s.beacon.clock.Now())
 }
 
 func (s *Session) TraceWebRequestAt(url string, timestamp time.Time) interfaces.WebRequestTracer {
@@ -224,7 +245,7 @@ func (s *Session) tryEnd() bool {
 		return true
 	}
 	if s.getChildCount() == 0 {
-		s.endWithEvent(false, time.Now())
+		s.endWithEvent(false, s.beacon.clock.Now())
 		return true
 	}
 	s.State.MarkAsWasTriedForEnding()
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"container/list"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"sync"
+	"time"
+)
+
+type managedSession struct {
+	userID     string
+	session    interfaces.Session
+	lastAccess time.Time
+}
+
+// SessionManager maps user IDs to sessions. Idle sessions are ended by the session watchdog,
+// so they can stay open up to SESSION_WATCHDOG_DEFAULT_SLEEP_TIME longer than the idle timeout.
+type SessionManager struct {
+	log     log.Logger
+	openKit *OpenKit
+	options interfaces.SessionManagerOptions
+
+	mutex      sync.Mutex
+	sessions   map[string]*list.Element
+	lru        *list.List // most recently used at the front
+	isShutDown bool
+}
+
+func (o *OpenKit) CreateSessionManager(options interfaces.SessionManagerOptions) interfaces.SessionManager {
+	m := &SessionManager{
+		log:      o.log,
+		openKit:  o,
+		options:  options,
+		sessions: map[string]*list.Element{},
+		lru:      list.New(),
+	}
+	if options.IdleTimeout > 0 {
+		o.sessionWatchdog.AddSessionManager(m)
+	}
+	return m
+}
+
+// GetOrCreate returns the session of the user, sessions that were ended by the caller are replaced by a new one
+func (m *SessionManager) GetOrCreate(userID string) interfaces.Session {
+	return m.GetOrCreateWithOptions(userID, interfaces.SessionOptions{})
+}
+
+func (m *SessionManager) GetOrCreateWithOptions(userID string, options interfaces.SessionOptions) interfaces.Session {
+	m.mutex.Lock()
+	session, evicted := m.getOrCreate(userID, options)
+	m.mutex.Unlock()
+
+	endSessions(evicted)
+	return session
+}
+
+func (m *SessionManager) getOrCreate(userID string, options interfaces.SessionOptions) (interfaces.Session, []interfaces.Session) {
+	if m.isShutDown {
+		return NewNullSession(), nil
+	}
+
+	now := m.openKit.clock.Now()
+	if element, ok := m.sessions[userID]; ok {
+		entry := element.Value.(*managedSession)
+		if proxy, ok := entry.session.(*SessionProxy); !ok || !proxy.isEnded() {
+			entry.lastAccess = now
+			m.lru.MoveToFront(element)
+			return entry.session, nil
+		}
+		m.removeElement(element)
+	}
+
+	if options.DeviceID == nil {
+		deviceID := DeviceIDFromString(userID)
+		options.DeviceID = &deviceID
+	}
+	options.Timestamp = now
+	session := m.openKit.CreateSessionWithOptions(options)
+	m.sessions[userID] = m.lru.PushFront(&managedSession{userID: userID, session: session, lastAccess: now})
+
+	var evicted []interfaces.Session
+	if m.options.MaxSessions > 0 {
+		for m.lru.Len() > m.options.MaxSessions {
+			m.log.WithFields(log.Fields{"userID": m.lru.Back().Value.(*managedSession).userID}).Debug("SessionManager ending least recently used session")
+			evicted = append(evicted, m.removeElement(m.lru.Back()))
+		}
+	}
+	return session, evicted
+}
+
+func (m *SessionManager) End(userID string) {
+	m.mutex.Lock()
+	element, ok := m.sessions[userID]
+	if ok {
+		m.removeElement(element)
+	}
+	m.mutex.Unlock()
+
+	if ok {
+		element.Value.(*managedSession).session.End()
+	}
+}
+
+func (m *SessionManager) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.lru.Len()
+}
+
+func (m *SessionManager) Shutdown() {
+	m.log.Debug("SessionManager.Shutdown()")
+	m.openKit.sessionWatchdog.RemoveSessionManager(m)
+
+	m.mutex.Lock()
+	m.isShutDown = true
+	var sessions []interfaces.Session
+	for m.lru.Len() > 0 {
+		sessions = append(sessions, m.removeElement(m.lru.Back()))
+	}
+	m.mutex.Unlock()
+
+	endSessions(sessions)
+}
+
+// endIdleSessions ends all sessions idle for longer than the idle timeout and
+// returns the duration until the next session becomes idle
+func (m *SessionManager) endIdleSessions(now time.Time) time.Duration {
+	m.mutex.Lock()
+	sleepTime, idle := m.removeIdleSessions(now)
+	m.mutex.Unlock()
+
+	endSessions(idle)
+	return sleepTime
+}
+
+func (m *SessionManager) removeIdleSessions(now time.Time) (time.Duration, []interfaces.Session) {
+	var idle []interfaces.Session
+	for m.lru.Len() > 0 {
+		entry := m.lru.Back().Value.(*managedSession)
+		idleEnd := entry.lastAccess.Add(m.options.IdleTimeout)
+		if now.Before(idleEnd) {
+			return idleEnd.Sub(now), idle
+		}
+		m.log.WithFields(log.Fields{"userID": entry.userID}).Debug("SessionManager ending idle session")
+		idle = append(idle, m.removeElement(m.lru.Back()))
+	}
+	return m.options.IdleTimeout, idle
+}
+
+// removeElement removes the session from the manager without ending it, so it can be ended after unlocking
+func (m *SessionManager) removeElement(element *list.Element) interfaces.Session {
+	entry := m.lru.Remove(element).(*managedSession)
+	delete(m.sessions, entry.userID)
+	return entry.session
+}
+
+func endSessions(sessions []interfaces.Session) {
+	for _, session := range sessions {
+		session.End()
+	}
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers/providerstest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newTestSessionManager(clock *providerstest.FakeClock, options interfaces.SessionManagerOptions) *SessionManager {
+	builder := NewOpenKitBuilder("https://localhost:9999/mbeacon", "app", 1).
+		WithLogLevel(log.WarnLevel).
+		WithClock(clock)
+	ok := NewOpenKit(builder.(*OpenKitBuilder)).(*OpenKit)
+	return ok.CreateSessionManager(options).(*SessionManager)
+}
+
+func TestSessionManagerReusesSessions(t *testing.T) {
+	m := newTestSessionManager(providerstest.NewFakeClock(time.Now()), interfaces.SessionManagerOptions{})
+
+	s1 := m.GetOrCreate("user-1")
+	assert.True(t, s1 == m.GetOrCreate("user-1"))
+	s2 := m.GetOrCreate("user-2")
+	assert.True(t, s1 != s2)
+	assert.Equal(t, 2, m.Len())
+
+	assert.Equal(t, DeviceIDFromString("user-1"), s1.(*SessionProxy).currentSession.beacon.deviceID)
+	assert.NotEqual(t, DeviceIDFromString("user-1"), DeviceIDFromString("user-2"))
+
+	m.End("user-1")
+	assert.True(t, s1.(*SessionProxy).isFinished)
+	assert.Equal(t, 1, m.Len())
+}
+
+func TestSessionManagerGetOrCreateWithOptions(t *testing.T) {
+	m := newTestSessionManager(providerstest.NewFakeClock(time.Now()), interfaces.SessionManagerOptions{})
+
+	deviceID := int64(42)
+	s1 := m.GetOrCreateWithOptions("user-1", interfaces.SessionOptions{ClientIP: "10.0.0.1", DeviceID: &deviceID})
+	assert.Equal(t, int64(42), s1.(*SessionProxy).currentSession.beacon.deviceID)
+	assert.Equal(t, "10.0.0.1", s1.(*SessionProxy).clientIPAddress)
+
+	// options are ignored for existing sessions
+	assert.True(t, s1 == m.GetOrCreateWithOptions("user-1", interfaces.SessionOptions{ClientIP: "10.0.0.2"}))
+
+	s2 := m.GetOrCreateWithOptions("user-2", interfaces.SessionOptions{ClientIP: "10.0.0.2"})
+	assert.Equal(t, DeviceIDFromString("user-2"), s2.(*SessionProxy).currentSession.beacon.deviceID)
+}
+
+func TestSessionManagerReplacesSessionsEndedByTheCaller(t *testing.T) {
+	m := newTestSessionManager(providerstest.NewFakeClock(time.Now()), interfaces.SessionManagerOptions{})
+
+	s1 := m.GetOrCreate("user-1")
+	s1.End()
+
+	s2 := m.GetOrCreate("user-1")
+	assert.True(t, s1 != s2)
+	assert.False(t, s2.(*SessionProxy).isFinished)
+	assert.Equal(t, 1, m.Len())
+}
+
+// callbackSession calls back into the session manager when it is ended
+type callbackSession struct {
+	NullSession
+	onEnd func()
+}
+
+func (s *callbackSession) End() { s.onEnd() }
+
+func TestSessionManagerEndsSessionsWithoutHoldingTheLock(t *testing.T) {
+	m := newTestSessionManager(providerstest.NewFakeClock(time.Now()), interfaces.SessionManagerOptions{MaxSessions: 1})
+
+	ended := make(chan int, 1)
+	session := &callbackSession{onEnd: func() { ended <- m.Len() }}
+	m.sessions["user-1"] = m.lru.PushFront(&managedSession{userID: "user-1", session: session})
+
+	done := make(chan bool)
+	go func() {
+		m.GetOrCreate("user-2")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GetOrCreate deadlocked while ending the least recently used session")
+	}
+	assert.Equal(t, 1, <-ended)
+}
+
+func TestSessionManagerEndsLeastRecentlyUsed(t *testing.T) {
+	m := newTestSessionManager(providerstest.NewFakeClock(time.Now()), interfaces.SessionManagerOptions{MaxSessions: 2})
+
+	s1 := m.GetOrCreate("user-1")
+	s2 := m.GetOrCreate("user-2")
+	m.GetOrCreate("user-1")
+	m.GetOrCreate("user-3")
+
+	assert.Equal(t, 2, m.Len())
+	assert.False(t, s1.(*SessionProxy).isFinished)
+	assert.True(t, s2.(*SessionProxy).isFinished)
+}
+
+func TestSessionManagerEndsIdleSessions(t *testing.T) {
+	clock := providerstest.NewFakeClock(time.Now())
+	m := newTestSessionManager(clock, interfaces.SessionManagerOptions{IdleTimeout: 30 * time.Minute})
+
+	s1 := m.GetOrCreate("user-1")
+	clock.Advance(10 * time.Minute)
+	s2 := m.GetOrCreate("user-2")
+	clock.Advance(25 * time.Minute)
+
+	assert.Equal(t, 5*time.Minute, m.endIdleSessions(clock.Now()))
+	assert.True(t, s1.(*SessionProxy).isFinished)
+	assert.False(t, s2.(*SessionProxy).isFinished)
+	assert.Equal(t, 1, m.Len())
+}
+
+func TestSessionManagerIsDrivenBySessionWatchdog(t *testing.T) {
+	clock := providerstest.NewFakeClock(time.Now())
+	m := newTestSessionManager(clock, interfaces.SessionManagerOptions{IdleTimeout: time.Minute})
+	watchdog := m.openKit.sessionWatchdog
+
+	m.GetOrCreate("user-1")
+	watchdog.Initialize()
+	defer watchdog.Shutdown()
+
+	clock.BlockUntil(1)
+	clock.Advance(2 * time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for m.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestSessionManagerShutdown(t *testing.T) {
+	m := newTestSessionManager(providerstest.NewFakeClock(time.Now()), interfaces.SessionManagerOptions{IdleTimeout: time.Minute})
+
+	s1 := m.GetOrCreate("user-1")
+	m.Shutdown()
+
+	assert.True(t, s1.(*SessionProxy).isFinished)
+	assert.Equal(t, 0, m.Len())
+	assert.IsType(t, &NullSession{}, m.GetOrCreate("user-1"))
+	assert.Empty(t, m.openKit.sessionWatchdog.ctx.sessionManagers)
+}
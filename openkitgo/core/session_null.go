@@ -1,6 +1,7 @@
 package core
 
 import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
 	"time"
 )
@@ -60,3 +61,11 @@ func (n NullSession) ReportCrashAt(errorName string, reason string, stacktrace s
 func (n NullSession) End()                      {}
 func (n NullSession) EndAt(timestamp time.Time) {}
 func (n NullSession) String() string            { return "NullSession" }
+
+func (n NullSession) SetDataCollectionLevel(level configuration.DataCollectionLevel) {
+
+}
+
+func (n NullSession) SetCrashReportingLevel(level configuration.CrashReportingLevel) {
+
+}
@@ -5,8 +5,11 @@ import (
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
-	log "github.com/sirupsen/logrus"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/redaction"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -18,21 +21,28 @@ type ServerConfigurationUpdateCallback interface {
 type SessionProxy struct {
 
 	// From java SessionProxyImpl
-	log                  *log.Logger
+	log                  log.Logger
 	parent               OpenKitComposite
 	openKitConfiguration *configuration.OpenKitConfiguration
 	privacyConfiguration *configuration.PrivacyConfiguration
 	beaconSender         *BeaconSender
 	sessionWatchdog      *SessionWatchdog
+	clock                providers.Clock
 	currentSession       *Session
 	topLevelActionCount  int
 	lastInteractionTime  time.Time
 	serverConfiguration  *configuration.ServerConfiguration
 	isFinished           bool
 	lastUserTag          string
+	attributes           map[string]string
 
 	// From java SessionCreatorImpl
-	beaconCache           *caching.BeaconCache
+	beaconCache           caching.BeaconCache
+	sessionIDProvider     providers.SessionIDProvider
+	randomNumberGenerator providers.RandomNumberGenerator
+	threadIDProvider      providers.ThreadIDProvider
+	redactor              *redaction.Redactor
+	beforeSend            interfaces.BeforeSendFunc
 	clientIPAddress       string
 	serverID              int
 	sessionSequenceNumber int32
@@ -42,39 +52,62 @@ type SessionProxy struct {
 }
 
 func NewSessionProxy(
-	log *log.Logger,
+	log log.Logger,
 	parent OpenKitComposite,
 	beaconSender *BeaconSender,
 	sessionWatchdog *SessionWatchdog,
 	input *OpenKit,
-	clientIPAddress string,
-	timestamp time.Time,
+	options interfaces.SessionOptions,
 ) *SessionProxy {
+	// Every proxy gets its own copy, sessions with other device settings must not change it
+	openKitConfig := *input.openKitConfiguration
+	if options.DeviceID != nil {
+		openKitConfig.DeviceID = *options.DeviceID
+		openKitConfig.OrigDeviceID = strconv.FormatInt(*options.DeviceID, 10)
+	}
+	privacyConfig := input.privacyConfiguration.Copy()
+	if options.DataCollectionLevel != nil {
+		privacyConfig.SetDataCollectionLevel(*options.DataCollectionLevel)
+	}
+	attributes := make(map[string]string, len(options.Attributes))
+	for key, value := range options.Attributes {
+		attributes[key] = value
+	}
+
 	p := &SessionProxy{
 		// Proxy
 		log:          log,
 		parent:       parent,
 		beaconSender: beaconSender,
+		clock:        input.clock,
 
 		// Creator
-		openKitConfiguration: input.openKitConfiguration,
-		privacyConfiguration: input.privacyConfiguration,
+		openKitConfiguration: &openKitConfig,
+		privacyConfiguration: privacyConfig,
 		beaconCache:          input.beaconCache,
-		clientIPAddress:      clientIPAddress,
-		serverID:             beaconSender.GetCurrentServerId(),
+		// Split sessions keep the session number and only increase the sequence number
+		sessionIDProvider:     providers.NewFixedSessionIDProvider(input.sessionIDProvider),
+		randomNumberGenerator: input.randomNumberGenerator,
+		threadIDProvider:      input.threadIDProvider,
+		redactor:              input.redactor,
+		beforeSend:            input.beforeSend,
+		clientIPAddress:       options.ClientIP,
+		serverID:              beaconSender.GetCurrentServerId(),
 
 		currentSession:      nil,
 		topLevelActionCount: 0,
 		lastInteractionTime: time.Time{},
 		isFinished:          false,
-		lastUserTag:         "",
+		lastUserTag:         options.UserTag,
+		attributes:          attributes,
 		sessionWatchdog:     sessionWatchdog,
 
 		children: []OpenKitObject{},
 	}
 
 	currentServerConfig := beaconSender.GetLastServerConfiguration()
-	p.createInitialSessionAndMakeCurrent(currentServerConfig, timestamp)
+	p.createInitialSessionAndMakeCurrent(currentServerConfig, options.Timestamp)
+	p.reTagCurrentSessionAt(options.Timestamp)
 
 	return p
 }
@@ -124,7 +157,7 @@ func (p *SessionProxy) getActionID() int {
 }
 
 func (p *SessionProxy) EnterAction(actionName string) interfaces.Action {
-	return p.EnterActionAt(actionName, time.Now())
+	return p.EnterActionAt(actionName, p.clock.Now())
 }
 
 func (p *SessionProxy) EnterActionAt(actionName string, timestamp time.Time) interfaces.Action {
@@ -144,7 +177,7 @@ func (p *SessionProxy) EnterActionAt(actionName string, timestamp time.Time) int
 }
 
 func (p *SessionProxy) IdentifyUser(userTag string) {
-	p.IdentifyUserAt(userTag, time.Now())
+	p.IdentifyUserAt(userTag, p.clock.Now())
 }
 
 func (p *SessionProxy) IdentifyUserAt(userTag string, timestamp time.Time) {
@@ -152,7 +185,7 @@ func (p *SessionProxy) IdentifyUserAt(userTag string, timestamp time.Time) {
 
 	if !p.isFinished {
 		s := p.getOrSplitCurrentSessionByEvents(timestamp)
-		p.lastInteractionTime = time.Now()
+		p.lastInteractionTime = p.clock.Now()
 		s.IdentifyUserAt(userTag, timestamp)
 		p.lastUserTag = userTag
 
@@ -160,7 +193,7 @@ func (p *SessionProxy) IdentifyUserAt(userTag string, timestamp time.Time) {
 }
 
 func (p *SessionProxy) ReportCrash(errorName string, reason string, stacktrace string) {
-	p.ReportCrashAt(errorName, reason, stacktrace, time.Now())
+	p.ReportCrashAt(errorName, reason, stacktrace, p.clock.Now())
 }
 
 func (p *SessionProxy) ReportCrashAt(errorName string, reason string, stacktrace string, timestamp time.Time) {
@@ -174,7 +207,13 @@ func (p *SessionProxy) ReportCrashAt(errorName string, reason string, stacktrace
 	}
 }
 func (p *SessionProxy) End() {
-	p.EndAt(time.Now())
+	p.EndAt(p.clock.Now())
+}
+
+func (p *SessionProxy) isEnded() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.isFinished
 }
 
 func (p *SessionProxy) EndAt(timestamp time.Time) {
@@ -194,6 +233,28 @@ func (p *SessionProxy) EndAt(timestamp time.Time) {
 
 }
 
+// SetDataCollectionLevel applies to the current session and all sessions split off later
+func (p *SessionProxy) SetDataCollectionLevel(level configuration.DataCollectionLevel) {
+	p.log.WithFields(log.Fields{"level": level}).Debug("SessionProxy.SetDataCollectionLevel()")
+	p.privacyConfiguration.SetDataCollectionLevel(level)
+	p.purgeDisallowedData()
+}
+
+// SetCrashReportingLevel applies to the current session and all sessions split off later
+func (p *SessionProxy) SetCrashReportingLevel(level configuration.CrashReportingLevel) {
+	p.log.WithFields(log.Fields{"level": level}).Debug("SessionProxy.SetCrashReportingLevel()")
+	p.privacyConfiguration.SetCrashReportingLevel(level)
+	p.purgeDisallowedData()
+}
+
+func (p *SessionProxy) purgeDisallowedData() {
+	for _, child := range p.getCopyOfChildObjects() {
+		if session, ok := child.(*Session); ok {
+			session.beacon.purgeDisallowedData()
+		}
+	}
+}
+
 func (p *SessionProxy) String() string {
 	return fmt.Sprintf("SessionProxy")
 }
@@ -203,7 +264,7 @@ func (p *SessionProxy) closeAt(timestamp time.Time) {
 }
 
 func (p *SessionProxy) close() {
-	p.closeAt(time.Now())
+	p.closeAt(p.clock.Now())
 }
 
 func (p *SessionProxy) GetSessionSequenceNumber() int32 {
@@ -260,7 +321,7 @@ func (p *SessionProxy) createSessionAt(parent OpenKitComposite, timestamp time.T
 	beacon := NewBeacon(
 		p.log,
 		p.beaconCache,
-		providers.NewSessionIDProvider(),
+		p.sessionIDProvider,
 		p,
 		config,
 		timestamp,
@@ -329,20 +390,33 @@ func (p *SessionProxy) onServerConfigurationUpdate(serverConfiguration *configur
 func (p *SessionProxy) splitAndCreateNewInitialSession() {
 	p.closeOrEnqueueCurrentSessionForClosing()
 	p.sessionSequenceNumber = 0
-	p.createInitialSessionAndMakeCurrent(p.serverConfiguration, time.Now())
+	p.createInitialSessionAndMakeCurrent(p.serverConfiguration, p.clock.Now())
 	p.reTagCurrentSession()
 
 }
 
 func (p *SessionProxy) reTagCurrentSession() {
+	p.reTagCurrentSessionAt(p.clock.Now())
+}
+
+// reTagCurrentSessionAt applies the user tag and the session attributes to the current session
+func (p *SessionProxy) reTagCurrentSessionAt(timestamp time.Time) {
 	if p.lastUserTag != "" {
-		p.currentSession.IdentifyUser(p.lastUserTag)
+		p.currentSession.IdentifyUserAt(p.lastUserTag, timestamp)
 	}
 
+	keys := make([]string, 0, len(p.attributes))
+	for key := range p.attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		p.currentSession.reportAttribute(key, p.attributes[key], timestamp)
+	}
 }
 
 func (p *SessionProxy) TraceWebRequest(url string) interfaces.WebRequestTracer {
-	return p.TraceWebRequestAt(url, time.Now())
+	return p.TraceWebRequestAt(url, p.clock.Now())
 }
 
 func (p *SessionProxy) TraceWebRequestAt(url string, timestamp time.Time) interfaces.WebRequestTracer {
@@ -363,7 +437,7 @@ func (p *SessionProxy) splitSessionByTime() time.Time {
 	}
 
 	nextSplitTime := p.calculateNextSplitTime()
-	now := time.Now()
+	now := p.clock.Now()
 
 	if nextSplitTime.IsZero() || now.Before(nextSplitTime) {
 		return nextSplitTime
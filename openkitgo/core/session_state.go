@@ -38,8 +38,8 @@ func (s *SessionState) IsFinishingOrFinished() bool {
 }
 
 func (s *SessionState) MarkAsIsFinishing() bool {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	if s.IsFinishingOrFinished() {
 		return false
 	}
@@ -48,14 +48,14 @@ func (s *SessionState) MarkAsIsFinishing() bool {
 }
 
 func (s *SessionState) MarkAsFinished() {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	s.finished = true
 }
 
 func (s *SessionState) MarkAsWasTriedForEnding() {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	s.triedForEnding = true
 }
 
@@ -1,26 +1,27 @@
 package core
 
 import (
-	log "github.com/sirupsen/logrus"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"time"
 )
 
 type SessionWatchdog struct {
-	log *log.Logger
+	log log.Logger
 	ctx *SessionWatchdogContext
 }
 
-func NewSessionWatchdog(log *log.Logger, ctx *SessionWatchdogContext) *SessionWatchdog {
+func NewSessionWatchdog(log log.Logger, ctx *SessionWatchdogContext) *SessionWatchdog {
 	return &SessionWatchdog{
 		log: log,
 		ctx: ctx,
 	}
 }
 
-func sessionWatchdogGoRoutine(log *log.Logger, ctx *SessionWatchdogContext) {
+func sessionWatchdogGoRoutine(log log.Logger, ctx *SessionWatchdogContext) {
 
 	go func() {
 		log.Debug("SessionWatchdogGoRoutine.run()")
+		defer close(ctx.done)
 		for !ctx.isShutdownRequested() {
 			ctx.execute()
 		}
@@ -37,6 +38,11 @@ func (w *SessionWatchdog) Shutdown() {
 	w.ctx.requestShutdown()
 }
 
+// Done is closed once the watchdog goroutine has stopped
+func (w *SessionWatchdog) Done() <-chan struct{} {
+	return w.ctx.done
+}
+
 func (w *SessionWatchdog) CloseOrEnqueueForClosing(session *Session, closeGracePeriod time.Duration) {
 	w.ctx.closeOrEnqueueForClosing(session, closeGracePeriod)
 }
@@ -48,6 +54,14 @@ func (w *SessionWatchdog) DequeueFromClosing(session *Session) {
 func (w *SessionWatchdog) AddToSplitByTimeout(session *SessionProxy) {
 	w.ctx.addToSplitByTimeout(session)
 }
+func (w *SessionWatchdog) AddSessionManager(manager *SessionManager) {
+	w.ctx.addSessionManager(manager)
+}
+
+func (w *SessionWatchdog) RemoveSessionManager(manager *SessionManager) {
+	w.ctx.removeSessionManager(manager)
+}
+
 func (w *SessionWatchdog) RemoveFromSplitByTimeout(session *SessionProxy) {
 	w.ctx.removeFromSplitByTimeout(session)
 }
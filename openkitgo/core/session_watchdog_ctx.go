@@ -1,6 +1,8 @@
 package core
 
 import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -10,36 +12,66 @@ const (
 )
 
 type SessionWatchdogContext struct {
+	clock                    providers.Clock
 	shutdown                 int32 // atomic
+	shutdownCh               chan struct{}
+	shutdownOnce             sync.Once
+	done                     chan struct{}
+	mutex                    sync.Mutex // guards sessionsToClose and sessionsToSplitByTimeout
 	sessionsToClose          []*Session
 	sessionsToSplitByTimeout []*SessionProxy
+
+	sessionManagersMutex sync.Mutex
+	sessionManagers      []*SessionManager
 }
 
-func NewSessionWatchdogContext() *SessionWatchdogContext {
-	return &SessionWatchdogContext{}
+func NewSessionWatchdogContext(clock providers.Clock) *SessionWatchdogContext {
+	return &SessionWatchdogContext{
+		clock:      clock,
+		shutdownCh: make(chan struct{}),
+		done:       make(chan struct{}),
+	}
 }
 
 func (c *SessionWatchdogContext) execute() {
 	durationToNextClose := c.closeExpiredSessions()
 	durationToNextSplit := c.splitTimedOutSessions()
+	durationToNextIdleEnd := c.endIdleManagedSessions()
+
+	sleepTime := durationToNextClose
+	if durationToNextSplit < sleepTime {
+		sleepTime = durationToNextSplit
+	}
+	if durationToNextIdleEnd < sleepTime {
+		sleepTime = durationToNextIdleEnd
+	}
+	c.sleep(sleepTime)
+}
 
-	if durationToNextSplit < durationToNextClose {
-		time.Sleep(durationToNextSplit)
-	} else {
-		time.Sleep(durationToNextClose)
+// sleep blocks for the given duration, or until a shutdown is requested
+func (c *SessionWatchdogContext) sleep(duration time.Duration) {
+	timer := c.clock.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+	case <-c.shutdownCh:
 	}
 }
 
 func (c *SessionWatchdogContext) splitTimedOutSessions() time.Duration {
 	sleepTime := SESSION_WATCHDOG_DEFAULT_SLEEP_TIME
 
-	for _, session := range c.sessionsToSplitByTimeout {
+	c.mutex.Lock()
+	sessions := append([]*SessionProxy(nil), c.sessionsToSplitByTimeout...)
+	c.mutex.Unlock()
+
+	for _, session := range sessions {
 		nextSessionSplitTime := session.splitSessionByTime()
 		if nextSessionSplitTime.IsZero() {
 			continue
 		}
 
-		now := time.Now()
+		now := c.clock.Now()
 		durationToNextSplit := nextSessionSplitTime.Sub(now)
 		if durationToNextSplit < 0 {
 			continue
@@ -58,8 +90,12 @@ func (c *SessionWatchdogContext) closeExpiredSessions() time.Duration {
 
 	var sessionsToEnd []*Session
 
-	for _, session := range c.sessionsToClose {
-		now := time.Now()
+	c.mutex.Lock()
+	sessions := append([]*Session(nil), c.sessionsToClose...)
+	c.mutex.Unlock()
+
+	for _, session := range sessions {
+		now := c.clock.Now()
 		gracePeriodEndTime := session.getSplitByEventsGracePeriodEndTime()
 		gracePeriodExpired := gracePeriodEndTime.Before(now)
 		if gracePeriodExpired {
@@ -73,7 +109,24 @@ func (c *SessionWatchdogContext) closeExpiredSessions() time.Duration {
 	}
 
 	for _, session := range sessionsToEnd {
-		session.endWithEvent(false, time.Now())
+		session.endWithEvent(false, c.clock.Now())
+	}
+
+	return sleepTime
+}
+
+func (c *SessionWatchdogContext) endIdleManagedSessions() time.Duration {
+	sleepTime := SESSION_WATCHDOG_DEFAULT_SLEEP_TIME
+
+	c.sessionManagersMutex.Lock()
+	managers := append([]*SessionManager(nil), c.sessionManagers...)
+	c.sessionManagersMutex.Unlock()
+
+	for _, manager := range managers {
+		durationToNextIdleEnd := manager.endIdleSessions(c.clock.Now())
+		if durationToNextIdleEnd < sleepTime {
+			sleepTime = durationToNextIdleEnd
+		}
 	}
 
 	return sleepTime
@@ -81,6 +134,9 @@ func (c *SessionWatchdogContext) closeExpiredSessions() time.Duration {
 
 func (c *SessionWatchdogContext) requestShutdown() {
 	atomic.StoreInt32(&c.shutdown, 1)
+	c.shutdownOnce.Do(func() {
+		close(c.shutdownCh)
+	})
 }
 
 func (c *SessionWatchdogContext) isShutdownRequested() bool {
@@ -91,12 +147,16 @@ func (c *SessionWatchdogContext) closeOrEnqueueForClosing(session *Session, clos
 	if session.tryEnd() {
 		return
 	}
-	closeTime := time.Now().Add(closeGracePeriod)
+	closeTime := c.clock.Now().Add(closeGracePeriod)
 	session.setSplitByEventsGracePeriodEndTime(closeTime)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.sessionsToClose = append(c.sessionsToClose, session)
 }
 
 func (c *SessionWatchdogContext) dequeueFromClosing(session *Session) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	var keep []*Session
 
 	for _, s := range c.sessionsToClose {
@@ -111,10 +171,14 @@ func (c *SessionWatchdogContext) addToSplitByTimeout(session *SessionProxy) {
 	if session.isFinished {
 		return
 	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.sessionsToSplitByTimeout = append(c.sessionsToSplitByTimeout, session)
 }
 
 func (c *SessionWatchdogContext) removeFromSplitByTimeout(session *SessionProxy) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	var keep []*SessionProxy
 
 	for _, s := range c.sessionsToSplitByTimeout {
@@ -125,3 +189,22 @@ func (c *SessionWatchdogContext) removeFromSplitByTimeout(session *SessionProxy)
 	c.sessionsToSplitByTimeout = keep
 
 }
+
+func (c *SessionWatchdogContext) addSessionManager(manager *SessionManager) {
+	c.sessionManagersMutex.Lock()
+	defer c.sessionManagersMutex.Unlock()
+	c.sessionManagers = append(c.sessionManagers, manager)
+}
+
+func (c *SessionWatchdogContext) removeSessionManager(manager *SessionManager) {
+	c.sessionManagersMutex.Lock()
+	defer c.sessionManagersMutex.Unlock()
+	var keep []*SessionManager
+
+	for _, m := range c.sessionManagers {
+		if m != manager {
+			keep = append(keep, m)
+		}
+	}
+	c.sessionManagers = keep
+}
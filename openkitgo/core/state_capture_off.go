@@ -7,8 +7,7 @@ import (
 )
 
 const (
-	STATUS_REQUEST_RETRIES = 5
-	STATUS_CHECK_INTERVAL  = 2 * time.Hour
+	STATUS_CHECK_INTERVAL = 2 * time.Hour
 )
 
 type StateCaptureOff struct {
@@ -28,7 +27,7 @@ func (s StateCaptureOff) terminal() bool {
 func (s StateCaptureOff) execute(ctx *BeaconSendingContext) {
 	ctx.disableCaptureAndClear()
 
-	currentTime := time.Now()
+	currentTime := ctx.getCurrentTimestamp()
 
 	var delta time.Duration
 	if s.sleepTime > 0 {
@@ -37,10 +36,10 @@ func (s StateCaptureOff) execute(ctx *BeaconSendingContext) {
 		delta = STATUS_CHECK_INTERVAL - (currentTime.Sub(ctx.lastStatusCheck))
 	}
 	if delta > 0 && !ctx.IsShutdownRequested() {
-		time.Sleep(delta)
+		ctx.sleepRejectingFlushes(delta, ErrCaptureOff)
 	}
 
-	statusResponse := sendStatusRequest(ctx, STATUS_REQUEST_RETRIES, INITIAL_RETRY_SLEEP_TIME_MILLISECONDS)
+	statusResponse := sendStatusRequest(ctx)
 	s.handleStatusResponse(ctx, statusResponse)
 	ctx.lastStatusCheck = currentTime
 
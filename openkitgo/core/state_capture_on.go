@@ -15,24 +15,39 @@ func NewStateCaptureOn() *StateCaptureOn {
 }
 
 func (s *StateCaptureOn) execute(ctx *BeaconSendingContext) {
-	time.Sleep(DEFAULT_SLEEP_TIME)
+	flushRequests := ctx.waitForWakeup(DEFAULT_SLEEP_TIME)
+	if len(flushRequests) > 0 {
+		// open sessions are sent right away instead of waiting for the send interval
+		ctx.lastOpenSessionSent = time.Time{}
+	}
+
+	var responses []protocol.StatusResponse
+	defer func() {
+		ctx.completeFlush(flushRequests, flushResult(responses))
+	}()
 
 	// send new session request for all sessions that are new
-	newSessionsResponse := s.sendNewSessionRequests(ctx)
+	newSessionsResponses := s.sendNewSessionRequests(ctx)
+	responses = append(responses, newSessionsResponses...)
+	newSessionsResponse := lastResponse(newSessionsResponses)
 	if newSessionsResponse.ResponseCode == http.StatusTooManyRequests {
 		ctx.nextState = NewStateCaptureOff(newSessionsResponse.GetRetryAfter())
 		return
 	}
 
 	// send all finished sessions
-	finishedSessionsResponse := s.sendFinishedSessions(ctx)
+	finishedSessionsResponses := s.sendFinishedSessions(ctx)
+	responses = append(responses, finishedSessionsResponses...)
+	finishedSessionsResponse := lastResponse(finishedSessionsResponses)
 	if finishedSessionsResponse.ResponseCode == http.StatusTooManyRequests {
 		ctx.nextState = NewStateCaptureOff(finishedSessionsResponse.GetRetryAfter())
 		return
 	}
 
 	// check if we need to send open sessions & do it if necessary
-	openSessionsResponse := s.sendOpenSessions(ctx)
+	openSessionsResponses := s.sendOpenSessions(ctx)
+	responses = append(responses, openSessionsResponses...)
+	openSessionsResponse := lastResponse(openSessionsResponses)
 	if openSessionsResponse.ResponseCode == http.StatusTooManyRequests {
 		ctx.nextState = NewStateCaptureOff(openSessionsResponse.GetRetryAfter())
 		return
@@ -51,6 +66,32 @@ func (s *StateCaptureOn) execute(ctx *BeaconSendingContext) {
 	}
 }
 
+// flushResult returns an error if any of the responses failed, with the response code of the last failure
+func flushResult(responses []protocol.StatusResponse) error {
+	var err *FlushError
+	for _, response := range responses {
+		if response.IsErroneousResponse() {
+			if err == nil {
+				err = &FlushError{}
+			}
+			err.ResponseCode = response.ResponseCode
+			err.FailedRequests++
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	return err
+}
+
+// lastResponse returns the last of responses, an empty StatusResponse if no request was sent
+func lastResponse(responses []protocol.StatusResponse) protocol.StatusResponse {
+	if len(responses) == 0 {
+		return protocol.StatusResponse{}
+	}
+	return responses[len(responses)-1]
+}
+
 func (s *StateCaptureOn) terminal() bool {
 	return false
 }
@@ -63,9 +104,9 @@ func (s *StateCaptureOn) String() string {
 	return "StateCaptureOn"
 }
 
-func (s *StateCaptureOn) sendNewSessionRequests(ctx *BeaconSendingContext) protocol.StatusResponse {
+func (s *StateCaptureOn) sendNewSessionRequests(ctx *BeaconSendingContext) []protocol.StatusResponse {
 
-	var statusResponse protocol.StatusResponse
+	var responses []protocol.StatusResponse
 
 	httpClient := ctx.getHttpClient()
 	for _, session := range ctx.getAllNotConfiguredSessions() {
@@ -74,7 +115,8 @@ func (s *StateCaptureOn) sendNewSessionRequests(ctx *BeaconSendingContext) proto
 			continue
 		}
 
-		statusResponse = httpClient.SendNewSessionRequest(ctx)
+		statusResponse := httpClient.SendNewSessionRequest(ctx)
+		responses = append(responses, statusResponse)
 		if statusResponse.ResponseCode < http.StatusBadRequest {
 			updatedAttributes := ctx.updateFrom(statusResponse)
 			newServerConfig := configuration.NewServerConfiguration(updatedAttributes)
@@ -86,16 +128,17 @@ func (s *StateCaptureOn) sendNewSessionRequests(ctx *BeaconSendingContext) proto
 		}
 	}
 
-	return statusResponse
+	return responses
 }
 
-func (s *StateCaptureOn) sendFinishedSessions(ctx *BeaconSendingContext) protocol.StatusResponse {
+func (s *StateCaptureOn) sendFinishedSessions(ctx *BeaconSendingContext) []protocol.StatusResponse {
 
-	statusResponse := protocol.StatusResponse{}
+	var responses []protocol.StatusResponse
 
 	for _, session := range ctx.getAllFinishedAndConfiguredSessions() {
 		if session.isDataSendingAllowed() {
-			statusResponse = session.sendBeacon(ctx)
+			statusResponse := session.sendBeacon(ctx)
+			responses = append(responses, statusResponse)
 			if statusResponse.ResponseCode >= http.StatusBadRequest {
 				if statusResponse.ResponseCode == http.StatusTooManyRequests || session.isEmpty() {
 					break
@@ -107,20 +150,21 @@ func (s *StateCaptureOn) sendFinishedSessions(ctx *BeaconSendingContext) protoco
 		session.close()
 	}
 
-	return statusResponse
+	return responses
 }
 
-func (s *StateCaptureOn) sendOpenSessions(ctx *BeaconSendingContext) protocol.StatusResponse {
-	statusResponse := protocol.StatusResponse{}
+func (s *StateCaptureOn) sendOpenSessions(ctx *BeaconSendingContext) []protocol.StatusResponse {
+	var responses []protocol.StatusResponse
 
-	currentTime := time.Now()
+	currentTime := ctx.getCurrentTimestamp()
 	if currentTime.Before(ctx.lastOpenSessionSent.Add(ctx.GetSendInterval())) {
-		return statusResponse
+		return responses
 	}
 
 	for _, session := range ctx.getAllOpenAndConfiguredSessions() {
 		if session.isDataSendingAllowed() {
-			statusResponse = session.sendBeacon(ctx)
+			statusResponse := session.sendBeacon(ctx)
+			responses = append(responses, statusResponse)
 			if statusResponse.ResponseCode == http.StatusTooManyRequests {
 				break
 			}
@@ -130,7 +174,7 @@ func (s *StateCaptureOn) sendOpenSessions(ctx *BeaconSendingContext) protocol.St
 	}
 
 	ctx.lastOpenSessionSent = currentTime
-	return statusResponse
+	return responses
 }
 
 func (s *StateCaptureOn) handleStatusResponse(ctx *BeaconSendingContext, response protocol.StatusResponse) {
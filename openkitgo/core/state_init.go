@@ -1,18 +1,13 @@
 package core
 
 import (
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/protocol"
-	log "github.com/sirupsen/logrus"
 	"math"
 	"net/http"
 	"time"
 )
 
-const (
-	MAX_INITIAL_STATUS_REQUEST_RETRIES    = 5
-	INITIAL_RETRY_SLEEP_TIME_MILLISECONDS = 1 * time.Second
-)
-
 type StateInit struct {
 	reInitDelayMilliseconds []time.Duration
 	reInitDelayIndex        int
@@ -36,8 +31,7 @@ func (s *StateInit) execute(ctx *BeaconSendingContext) {
 	statusResponse := s.executeStatusRequest(ctx)
 
 	if ctx.IsShutdownRequested() {
-		ctx.initWg.Done()
-		ctx.initOk = false
+		ctx.initCompleted(false)
 		ctx.nextState = s.getShutdownState()
 	} else if statusResponse.ResponseCode < http.StatusBadRequest {
 		ctx.handleStatusResponse(statusResponse)
@@ -47,8 +41,7 @@ func (s *StateInit) execute(ctx *BeaconSendingContext) {
 		} else {
 			ctx.nextState = NewStateCaptureOff(0)
 		}
-		ctx.initWg.Done()
-		ctx.initOk = true
+		ctx.initCompleted(true)
 	}
 
 	if ctx.IsShutdownRequested() {
@@ -67,7 +60,7 @@ func (s *StateInit) executeStatusRequest(ctx *BeaconSendingContext) protocol.Sta
 		ctx.lastOpenSessionSent = currentTimestamp
 		ctx.lastStatusCheck = currentTimestamp
 
-		statusResponse = sendStatusRequest(ctx, MAX_INITIAL_STATUS_REQUEST_RETRIES, INITIAL_RETRY_SLEEP_TIME_MILLISECONDS)
+		statusResponse = sendStatusRequest(ctx)
 		if ctx.IsShutdownRequested() || statusResponse.ResponseCode < http.StatusBadRequest {
 			// We are done, we are either shutting down or we got a good response
 			break
@@ -80,7 +73,7 @@ func (s *StateInit) executeStatusRequest(ctx *BeaconSendingContext) protocol.Sta
 			ctx.disableCaptureAndClear()
 		}
 		ctx.log.WithFields(log.Fields{"sleepAmount": sleepTime}).Warning("Could not initialize openkit, sleeping")
-		time.Sleep(sleepTime)
+		ctx.sleepRejectingFlushes(sleepTime, ErrNotInitialized)
 		s.reInitDelayIndex = int(math.Min(float64(s.reInitDelayIndex+1), float64(len(s.reInitDelayMilliseconds)-1)))
 	}
 
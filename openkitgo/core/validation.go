@@ -0,0 +1,27 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError describes an invalid OpenKitBuilder setting
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+// ValidationErrors is returned by OpenKitBuilder.Validate and BuildE with all invalid settings
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return "invalid OpenKit configuration: " + strings.Join(messages, "; ")
+}
@@ -3,7 +3,7 @@ package core
 import (
 	"fmt"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
-	log "github.com/sirupsen/logrus"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"sync"
 	"time"
 )
@@ -13,7 +13,7 @@ const (
 )
 
 type WebRequestTracer struct {
-	log             *log.Logger
+	log             log.Logger
 	parent          OpenKitComposite
 	mutex           sync.RWMutex
 	tag             string
@@ -29,7 +29,7 @@ type WebRequestTracer struct {
 	responseCode    int
 }
 
-func NewWebRequestTracer(log *log.Logger, parent OpenKitComposite, url string, beacon *Beacon, timestamp time.Time) *WebRequestTracer {
+func NewWebRequestTracer(log log.Logger, parent OpenKitComposite, url string, beacon *Beacon, timestamp time.Time) *WebRequestTracer {
 
 	t := &WebRequestTracer{
 		log:             log,
@@ -39,6 +39,7 @@ func NewWebRequestTracer(log *log.Logger, parent OpenKitComposite, url string, b
 		startTime:       timestamp,
 		bytesReceived:   -1,
 		bytesSent:       -1,
+		responseCode:    -1,
 		endSequenceNo:   -1,
 		startSequenceNo: beacon.CreateSequenceNumber(),
 		parentActionID:  parent.getActionID(),
@@ -72,7 +73,7 @@ func (w *WebRequestTracer) SetBytesReceived(bytesReceived int) interfaces.WebReq
 }
 
 func (w *WebRequestTracer) Start() interfaces.WebRequestTracer {
-	return w.StartAt(time.Now())
+	return w.StartAt(w.beacon.clock.Now())
 }
 
 func (w *WebRequestTracer) StartAt(timestamp time.Time) interfaces.WebRequestTracer {
@@ -88,7 +89,7 @@ func (w *WebRequestTracer) StartAt(timestamp time.Time) interfaces.WebRequestTra
 }
 
 func (w *WebRequestTracer) Stop(responseCode int) {
-	w.StopAt(responseCode, time.Now())
+	w.StopAt(responseCode, w.beacon.clock.Now())
 }
 
 func (w *WebRequestTracer) StopAt(responseCode int, timestamp time.Time) {
@@ -119,7 +120,7 @@ func (w *WebRequestTracer) isStopped() bool {
 }
 
 func (w *WebRequestTracer) close() {
-	w.closeAt(time.Now())
+	w.closeAt(w.beacon.clock.Now())
 }
 
 func (w *WebRequestTracer) closeAt(timestamp time.Time) {
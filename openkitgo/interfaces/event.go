@@ -0,0 +1,51 @@
+package interfaces
+
+import (
+	"time"
+)
+
+// EventType is the type of an event as it is sent in the beacon
+type EventType int
+
+const (
+	ACTION        EventType = 1
+	VALUE_STRING  EventType = 11
+	VALUE_INT     EventType = 12
+	VALUE_DOUBLE  EventType = 13
+	NAMED_EVENT   EventType = 10
+	SESSION_START EventType = 18
+	SESSION_END   EventType = 19
+	WEB_REQUEST   EventType = 30
+	ERROR         EventType = 40
+	EXCEPTION     EventType = 42
+	CRASH         EventType = 50
+	IDENTIFY_USER EventType = 60
+)
+
+// Keys of Event.Fields
+const (
+	EVENT_FIELD_VALUE          = "value"         // VALUE_*, string, int or float64, the type follows the value and nil drops the event
+	EVENT_FIELD_CAUSE          = "cause"         // EXCEPTION, string
+	EVENT_FIELD_REASON         = "reason"        // EXCEPTION and CRASH, string
+	EVENT_FIELD_STACKTRACE     = "stacktrace"    // EXCEPTION and CRASH, string
+	EVENT_FIELD_BYTES_SENT     = "bytesSent"     // WEB_REQUEST, any integer type, -1 if unknown
+	EVENT_FIELD_BYTES_RECEIVED = "bytesReceived" // WEB_REQUEST, any integer type, -1 if unknown
+	EVENT_FIELD_RESPONSE_CODE  = "responseCode"  // WEB_REQUEST, any integer type, -1 if unknown
+)
+
+// Event is a structured view of an action, value, named event, web request, error, crash or user tag
+// before it is encoded into the beacon. The user tag of IDENTIFY_USER events is their Name.
+// Changes to Type are ignored.
+type Event struct {
+	Type           EventType
+	Name           string
+	ParentActionID int
+	StartTime      time.Time
+	// EndTime is only set for ACTION and WEB_REQUEST events
+	EndTime time.Time
+	Fields  map[string]interface{}
+}
+
+// BeforeSendFunc receives every event before it is cached and returns the event to report, or nil to drop it.
+// It is called from the goroutines that report data and must be safe for concurrent use.
+type BeforeSendFunc func(event *Event) *Event
@@ -1,8 +1,13 @@
 package interfaces
 
 import (
+	"context"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/caching"
 	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
-	log "github.com/sirupsen/logrus"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/redaction"
+	"math/rand"
 	"net/http"
 	"time"
 )
@@ -10,29 +15,76 @@ import (
 type OpenKitBuilder interface {
 	WithApplicationName(applicationName string) OpenKitBuilder
 	WithLogLevel(level log.Level) OpenKitBuilder
-	WithLogger(log *log.Logger) OpenKitBuilder
+	WithLogger(log log.Logger) OpenKitBuilder
 	WithApplicationVersion(version string) OpenKitBuilder
 	WithTransport(transport *http.Transport) OpenKitBuilder
+	WithHTTPClient(client *http.Client) OpenKitBuilder
+	WithRoundTripper(roundTripper http.RoundTripper) OpenKitBuilder
+	WithClock(clock providers.Clock) OpenKitBuilder
+	WithRandomSource(source rand.Source) OpenKitBuilder
+	WithRandomNumberGenerator(random providers.RandomNumberGenerator) OpenKitBuilder
+	WithSessionIDProvider(provider providers.SessionIDProvider) OpenKitBuilder
+	WithThreadIDProvider(provider providers.ThreadIDProvider) OpenKitBuilder
+	WithRedactor(redactor *redaction.Redactor) OpenKitBuilder
+	WithBeforeSend(beforeSend func(event *Event) *Event) OpenKitBuilder
+	WithConnectTimeout(timeout time.Duration) OpenKitBuilder
+	WithReadTimeout(timeout time.Duration) OpenKitBuilder
+	WithRequestTimeout(timeout time.Duration) OpenKitBuilder
+	WithRetryPolicy(policy configuration.RetryPolicy) OpenKitBuilder
+	WithCACertificates(pemCerts []byte) OpenKitBuilder
+	WithCACertificatesFile(file string) OpenKitBuilder
+	WithClientCertificate(certPEM []byte, keyPEM []byte) OpenKitBuilder
+	WithClientCertificateFile(certFile string, keyFile string) OpenKitBuilder
+	WithInsecureSkipVerify() OpenKitBuilder
 	WithOperatingSystem(operatingSystem string) OpenKitBuilder
 	WithManufacturer(manufacturer string) OpenKitBuilder
 	WithModelID(modelID string) OpenKitBuilder
 	WithBeaconCacheMaxRecordAge(maxRecordAge time.Duration) OpenKitBuilder
 	WithBeaconCacheLowerMemoryBoundary(m int64) OpenKitBuilder
 	WithBeaconCacheUpperMemoryBoundary(m int64) OpenKitBuilder
+	WithBeaconCacheEvictionInterval(interval time.Duration) OpenKitBuilder
+	WithBeaconCacheDirectory(dir string) OpenKitBuilder
+	WithBeaconCache(cache caching.BeaconCache) OpenKitBuilder
 	WithDataCollectionLevel(l configuration.DataCollectionLevel) OpenKitBuilder
 	WithCrashReportingLevel(l configuration.CrashReportingLevel) OpenKitBuilder
 	WithTechnology(technology string) OpenKitBuilder
+	Validate() error
 	Build() OpenKit
+	BuildE() (OpenKit, error)
 }
 
 type OpenKit interface {
 	WaitForInitCompletion() bool
 	WaitForInitCompletionTimeout(duration time.Duration) bool
+	Flush(ctx context.Context) error
 	Shutdown()
+	ShutdownContext(ctx context.Context) error
 
 	CreateSession(clientIPAddress string) Session
 	CreateSessionAt(clientIPAddress string, timestamp time.Time) Session
 
 	CreateSessionWithDeviceID(clientIPAddress string, deviceID int64) Session
 	CreateSessionAtWithDeviceID(clientIPAddress string, timestamp time.Time, deviceID int64) Session
+	CreateSessionWithStringDeviceID(clientIPAddress string, deviceID string) Session
+	CreateSessionWithOptions(options SessionOptions) Session
+	CreateSessionManager(options SessionManagerOptions) SessionManager
+
+	// SetDataCollectionLevel changes the level of sessions created afterwards
+	SetDataCollectionLevel(level configuration.DataCollectionLevel)
+	// SetCrashReportingLevel changes the level of sessions created afterwards
+	SetCrashReportingLevel(level configuration.CrashReportingLevel)
+}
+
+// SessionOptions are the settings of a single session, unset fields fall back to the settings of the OpenKit
+type SessionOptions struct {
+	ClientIP  string
+	DeviceID  *int64
+	Timestamp time.Time
+	// UserTag identifies the user right away, it is also applied to sessions split off later
+	UserTag string
+	// Attributes are reported as string values (VALUE_STRING) with parent action 0, they are also applied to
+	// sessions split off later. Like other values they are only sent at DATA_USER_BEHAVIOR and BeforeSend sees
+	// them as VALUE_STRING events.
+	Attributes          map[string]string
+	DataCollectionLevel *configuration.DataCollectionLevel
 }
@@ -1,6 +1,7 @@
 package interfaces
 
 import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/configuration"
 	"time"
 )
 
@@ -20,5 +21,32 @@ type Session interface {
 	End()
 	EndAt(timestamp time.Time)
 
+	// SetDataCollectionLevel changes the level of this session, cached data the new level does not allow is removed
+	SetDataCollectionLevel(level configuration.DataCollectionLevel)
+	// SetCrashReportingLevel changes the level of this session, cached crashes the new level does not allow are removed
+	SetCrashReportingLevel(level configuration.CrashReportingLevel)
+
 	String() string
 }
+
+// SessionManager keeps one session per end user of a server side service
+type SessionManager interface {
+	// GetOrCreate returns the open session of userID, or creates one with a device ID derived from userID
+	// like a string device ID
+	GetOrCreate(userID string) Session
+	// GetOrCreateWithOptions is GetOrCreate, options are used if a new session is created.
+	// The device ID is derived from userID unless options.DeviceID is set.
+	GetOrCreateWithOptions(userID string, options SessionOptions) Session
+	// End ends the session of userID, if there is one
+	End(userID string)
+	Len() int
+	// Shutdown ends all sessions, GetOrCreate returns a null session afterwards
+	Shutdown()
+}
+
+type SessionManagerOptions struct {
+	// IdleTimeout ends sessions that were not returned by GetOrCreate for this long, zero disables it
+	IdleTimeout time.Duration
+	// MaxSessions ends the least recently used session when exceeded, zero means no limit
+	MaxSessions int
+}
@@ -0,0 +1,40 @@
+// Package logging defines the logger used by OpenKit, with adapters for logrus, log/slog and a no-op logger
+package logging
+
+type Fields map[string]interface{}
+
+// Logger is implemented by everything OpenKit can log to
+type Logger interface {
+	WithFields(fields Fields) Logger
+	Debug(msg string)
+	Info(msg string)
+	Warning(msg string)
+	Error(msg string)
+}
+
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+// LevelSetter is implemented by loggers whose level can be changed with OpenKitBuilder.WithLogLevel
+type LevelSetter interface {
+	SetLevel(level Level)
+}
+
+type nopLogger struct{}
+
+// NewNopLogger returns a Logger that discards everything
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+func (l nopLogger) WithFields(fields Fields) Logger { return l }
+func (l nopLogger) Debug(msg string)                {}
+func (l nopLogger) Info(msg string)                 {}
+func (l nopLogger) Warning(msg string)              {}
+func (l nopLogger) Error(msg string)                {}
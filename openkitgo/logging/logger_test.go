@@ -0,0 +1,26 @@
+package logging
+
+import (
+	"bytes"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestLogrusLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := logrus.New()
+	l.SetOutput(&buf)
+
+	logger := NewLogrusLogger(l)
+	logger.(LevelSetter).SetLevel(WarnLevel)
+
+	logger.WithFields(Fields{"session": 42}).Info("dropped")
+	logger.WithFields(Fields{"session": 42}).Warning("kept")
+
+	out := buf.String()
+	assert.False(t, strings.Contains(out, "dropped"))
+	assert.True(t, strings.Contains(out, "kept"))
+	assert.True(t, strings.Contains(out, "session=42"))
+}
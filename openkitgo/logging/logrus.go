@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+type logrusLogger struct {
+	logger *logrus.Logger
+	entry  *logrus.Entry
+}
+
+// NewLogrusLogger returns a Logger that writes to logger, or to a new logrus.Logger if logger is nil
+func NewLogrusLogger(logger *logrus.Logger) Logger {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &logrusLogger{logger: logger, entry: logrus.NewEntry(logger)}
+}
+
+func (l *logrusLogger) WithFields(fields Fields) Logger {
+	return &logrusLogger{logger: l.logger, entry: l.entry.WithFields(logrus.Fields(fields))}
+}
+
+func (l *logrusLogger) Debug(msg string)   { l.entry.Debug(msg) }
+func (l *logrusLogger) Info(msg string)    { l.entry.Info(msg) }
+func (l *logrusLogger) Warning(msg string) { l.entry.Warning(msg) }
+func (l *logrusLogger) Error(msg string)   { l.entry.Error(msg) }
+
+func (l *logrusLogger) SetLevel(level Level) {
+	switch level {
+	case DebugLevel:
+		l.logger.SetLevel(logrus.DebugLevel)
+	case InfoLevel:
+		l.logger.SetLevel(logrus.InfoLevel)
+	case WarnLevel:
+		l.logger.SetLevel(logrus.WarnLevel)
+	default:
+		l.logger.SetLevel(logrus.ErrorLevel)
+	}
+}
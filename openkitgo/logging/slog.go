@@ -0,0 +1,58 @@
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogLogger struct {
+	logger *slog.Logger
+	level  *slog.LevelVar
+}
+
+// NewSlogLogger returns a Logger that writes to logger, or to slog.Default() if logger is nil.
+// Records below the level set with SetLevel are dropped before they reach the handler.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	level := &slog.LevelVar{}
+	level.Set(slog.LevelDebug)
+	return &slogLogger{logger: logger, level: level}
+}
+
+func (l *slogLogger) WithFields(fields Fields) Logger {
+	args := make([]interface{}, 0, len(fields)*2)
+	for k, v := range fields {
+		args = append(args, k, v)
+	}
+	return &slogLogger{logger: l.logger.With(args...), level: l.level}
+}
+
+func (l *slogLogger) log(level slog.Level, msg string) {
+	if level < l.level.Level() {
+		return
+	}
+	l.logger.Log(context.Background(), level, msg)
+}
+
+func (l *slogLogger) Debug(msg string)   { l.log(slog.LevelDebug, msg) }
+func (l *slogLogger) Info(msg string)    { l.log(slog.LevelInfo, msg) }
+func (l *slogLogger) Warning(msg string) { l.log(slog.LevelWarn, msg) }
+func (l *slogLogger) Error(msg string)   { l.log(slog.LevelError, msg) }
+
+func (l *slogLogger) SetLevel(level Level) {
+	switch level {
+	case DebugLevel:
+		l.level.Set(slog.LevelDebug)
+	case InfoLevel:
+		l.level.Set(slog.LevelInfo)
+	case WarnLevel:
+		l.level.Set(slog.LevelWarn)
+	default:
+		l.level.Set(slog.LevelError)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	logger.(LevelSetter).SetLevel(InfoLevel)
+
+	logger.WithFields(Fields{"session": 42}).Debug("dropped")
+	logger.WithFields(Fields{"session": 42}).Error("kept")
+
+	out := buf.String()
+	assert.False(t, strings.Contains(out, "dropped"))
+	assert.True(t, strings.Contains(out, "kept"))
+	assert.True(t, strings.Contains(out, "session=42"))
+}
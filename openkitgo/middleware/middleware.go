@@ -0,0 +1,154 @@
+// Package middleware reports incoming HTTP requests as OpenKit user actions
+package middleware
+
+import (
+	"fmt"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// DEFAULT_IDLE_TIMEOUT ends the session of a visitor after 30 minutes without requests
+const DEFAULT_IDLE_TIMEOUT = 30 * time.Minute
+
+type Options struct {
+	// VisitorID extracts the user or visitor ID the session is keyed by.
+	// Requests for which it returns false are served without being reported.
+	VisitorID func(r *http.Request) (int64, bool)
+
+	// ActionName names the action created for a request, e.g. from the matched route template.
+	// Defaults to the request method and path.
+	ActionName func(r *http.Request) string
+
+	// ClientIP extracts the client IP address reported with new sessions.
+	// Defaults to the host part of the request's RemoteAddr.
+	ClientIP func(r *http.Request) string
+
+	// IdleTimeout ends the session of a visitor without requests for this long. Defaults to DEFAULT_IDLE_TIMEOUT.
+	IdleTimeout time.Duration
+
+	// MaxSessions ends the session of the least recently seen visitor when exceeded, zero means no limit.
+	MaxSessions int
+}
+
+type Middleware struct {
+	options  Options
+	sessions interfaces.SessionManager
+}
+
+func New(openKit interfaces.OpenKit, options Options) *Middleware {
+	if options.ActionName == nil {
+		options.ActionName = defaultActionName
+	}
+	if options.ClientIP == nil {
+		options.ClientIP = defaultClientIP
+	}
+	if options.IdleTimeout <= 0 {
+		options.IdleTimeout = DEFAULT_IDLE_TIMEOUT
+	}
+	return &Middleware{
+		options: options,
+		sessions: openKit.CreateSessionManager(interfaces.SessionManagerOptions{
+			IdleTimeout: options.IdleTimeout,
+			MaxSessions: options.MaxSessions,
+		}),
+	}
+}
+
+func defaultActionName(r *http.Request) string {
+	return r.Method + " " + r.URL.Path
+}
+
+func defaultClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Handler wraps next so that every request is reported as an action of the visitor's session.
+// The session and the action are available to next through the request context.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.options.VisitorID == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		visitorID, ok := m.options.VisitorID(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session := m.getOrCreateSession(visitorID, r)
+		action := session.EnterAction(m.options.ActionName(r))
+
+		ctx := openkitgo.ContextWithSession(r.Context(), session)
+		ctx = openkitgo.ContextWithAction(ctx, action)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			if p := recover(); p != nil {
+				if p != http.ErrAbortHandler {
+					session.ReportCrash(fmt.Sprintf("%T", p), fmt.Sprint(p), string(debug.Stack()))
+				}
+				action.LeaveAction()
+				panic(p)
+			}
+
+			if recorder.status >= http.StatusInternalServerError {
+				action.ReportError(fmt.Sprintf("HTTP %d", recorder.status), "", http.StatusText(recorder.status), "")
+			}
+			action.LeaveAction()
+		}()
+
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+	})
+}
+
+func (m *Middleware) getOrCreateSession(visitorID int64, r *http.Request) interfaces.Session {
+	return m.sessions.GetOrCreateWithOptions(strconv.FormatInt(visitorID, 10), interfaces.SessionOptions{
+		ClientIP: m.options.ClientIP(r),
+		DeviceID: &visitorID,
+	})
+}
+
+// Close ends all sessions created by the middleware, requests are served without being reported afterwards
+func (m *Middleware) Close() {
+	m.sessions.Shutdown()
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
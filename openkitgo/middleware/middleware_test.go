@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeAction struct {
+	interfaces.Action
+	name     string
+	errors   []string
+	children []*fakeAction
+	left     bool
+}
+
+func (a *fakeAction) EnterAction(actionName string) interfaces.Action {
+	child := &fakeAction{name: actionName}
+	a.children = append(a.children, child)
+	return child
+}
+
+func (a *fakeAction) ReportError(errorName string, causeName string, causeDescription string, causeStack string) interfaces.Action {
+	a.errors = append(a.errors, errorName)
+	return a
+}
+
+func (a *fakeAction) LeaveAction() interfaces.Action {
+	a.left = true
+	return nil
+}
+
+type fakeSession struct {
+	interfaces.Session
+	clientIP string
+	deviceID int64
+	actions  []*fakeAction
+	crashes  []string
+	ended    bool
+}
+
+func (s *fakeSession) EnterAction(actionName string) interfaces.Action {
+	action := &fakeAction{name: actionName}
+	s.actions = append(s.actions, action)
+	return action
+}
+
+func (s *fakeSession) ReportCrash(errorName string, reason string, stacktrace string) {
+	s.crashes = append(s.crashes, reason)
+}
+
+func (s *fakeSession) End() {
+	s.ended = true
+}
+
+type fakeOpenKit struct {
+	interfaces.OpenKit
+	managerOptions interfaces.SessionManagerOptions
+	sessions       []*fakeSession
+}
+
+func (o *fakeOpenKit) CreateSessionManager(options interfaces.SessionManagerOptions) interfaces.SessionManager {
+	o.managerOptions = options
+	return &fakeSessionManager{openKit: o, sessions: map[string]*fakeSession{}}
+}
+
+type fakeSessionManager struct {
+	interfaces.SessionManager
+	openKit  *fakeOpenKit
+	sessions map[string]*fakeSession
+}
+
+func (m *fakeSessionManager) GetOrCreateWithOptions(userID string, options interfaces.SessionOptions) interfaces.Session {
+	if s, ok := m.sessions[userID]; ok {
+		return s
+	}
+	s := &fakeSession{clientIP: options.ClientIP, deviceID: *options.DeviceID}
+	m.sessions[userID] = s
+	m.openKit.sessions = append(m.openKit.sessions, s)
+	return s
+}
+
+func (m *fakeSessionManager) Shutdown() {
+	for userID, s := range m.sessions {
+		s.End()
+		delete(m.sessions, userID)
+	}
+}
+
+func visitorFromHeader(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.Header.Get("X-Visitor"), 10, 64)
+	return id, err == nil
+}
+
+func serve(handler http.Handler, visitor string, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if visitor != "" {
+		req.Header.Set("X-Visitor", visitor)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareReportsActions(t *testing.T) {
+	ok := &fakeOpenKit{}
+	m := New(ok, Options{VisitorID: visitorFromHeader})
+
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, child := openkitgo.EnterActionContext(r.Context(), "child")
+		child.LeaveAction()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve(handler, "42", "/users")
+	serve(handler, "42", "/orders")
+	serve(handler, "7", "/users")
+	serve(handler, "", "/anonymous")
+
+	assert.Equal(t, 2, len(ok.sessions))
+	assert.Equal(t, int64(42), ok.sessions[0].deviceID)
+	assert.Equal(t, "192.0.2.1", ok.sessions[0].clientIP)
+	assert.Equal(t, 2, len(ok.sessions[0].actions))
+	assert.Equal(t, "GET /users", ok.sessions[0].actions[0].name)
+	assert.Equal(t, "GET /orders", ok.sessions[0].actions[1].name)
+	assert.True(t, ok.sessions[0].actions[0].left)
+	assert.Equal(t, "child", ok.sessions[0].actions[0].children[0].name)
+	assert.Empty(t, ok.sessions[0].actions[0].errors)
+
+	m.Close()
+	assert.True(t, ok.sessions[0].ended)
+	assert.True(t, ok.sessions[1].ended)
+}
+
+func TestMiddlewareEndsIdleSessions(t *testing.T) {
+	ok := &fakeOpenKit{}
+	New(ok, Options{VisitorID: visitorFromHeader})
+	assert.Equal(t, interfaces.SessionManagerOptions{IdleTimeout: DEFAULT_IDLE_TIMEOUT}, ok.managerOptions)
+
+	New(ok, Options{VisitorID: visitorFromHeader, IdleTimeout: time.Minute, MaxSessions: 100})
+	assert.Equal(t, interfaces.SessionManagerOptions{IdleTimeout: time.Minute, MaxSessions: 100}, ok.managerOptions)
+}
+
+func TestMiddlewareReportsServerErrors(t *testing.T) {
+	ok := &fakeOpenKit{}
+	m := New(ok, Options{
+		VisitorID:  visitorFromHeader,
+		ActionName: func(r *http.Request) string { return "/items/{id}" },
+	})
+
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	serve(handler, "1", "/items/3")
+
+	action := ok.sessions[0].actions[0]
+	assert.Equal(t, "/items/{id}", action.name)
+	assert.Equal(t, []string{"HTTP 502"}, action.errors)
+	assert.True(t, action.left)
+}
+
+func TestMiddlewareReportsPanics(t *testing.T) {
+	ok := &fakeOpenKit{}
+	m := New(ok, Options{VisitorID: visitorFromHeader})
+
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	assert.Panics(t, func() { serve(handler, "1", "/") })
+	assert.Equal(t, []string{"boom"}, ok.sessions[0].crashes)
+	assert.True(t, ok.sessions[0].actions[0].left)
+}
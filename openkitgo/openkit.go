@@ -8,3 +8,9 @@ import (
 func NewOpenKitBuilder(endpointURL string, applicationID string, deviceID int64) interfaces.OpenKitBuilder {
 	return core.NewOpenKitBuilder(endpointURL, applicationID, deviceID)
 }
+
+// NewOpenKitBuilderWithStringDeviceID uses numeric device IDs as they are and hashes other strings,
+// the same device ID gets the same visitor ID as with the Java and .NET OpenKits
+func NewOpenKitBuilderWithStringDeviceID(endpointURL string, applicationID string, deviceID string) interfaces.OpenKitBuilder {
+	return core.NewOpenKitBuilderWithStringDeviceID(endpointURL, applicationID, deviceID)
+}
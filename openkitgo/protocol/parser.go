@@ -2,7 +2,7 @@ package protocol
 
 import (
 	"encoding/json"
-	log "github.com/sirupsen/logrus"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"strconv"
 	"strings"
 	"time"
@@ -59,10 +59,10 @@ type DynamicConfig struct {
 }
 
 type ResponseParser struct {
-	log *log.Logger
+	log log.Logger
 }
 
-func NewResponseParser(log *log.Logger) ResponseParser {
+func NewResponseParser(log log.Logger) ResponseParser {
 	return ResponseParser{
 		log: log,
 	}
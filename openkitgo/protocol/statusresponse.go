@@ -1,7 +1,7 @@
 package protocol
 
 import (
-	log "github.com/sirupsen/logrus"
+	log "github.com/dlopes7/dynatrace-openkit-go/openkitgo/logging"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,13 +14,13 @@ const (
 )
 
 type StatusResponse struct {
-	log                *log.Logger
+	log                log.Logger
 	ResponseCode       int
 	Headers            http.Header
 	ResponseAttributes ResponseAttributes
 }
 
-func NewStatusResponse(log *log.Logger, attributes ResponseAttributes, responseCode int, headers http.Header) StatusResponse {
+func NewStatusResponse(log log.Logger, attributes ResponseAttributes, responseCode int, headers http.Header) StatusResponse {
 	return StatusResponse{
 		log:                log,
 		ResponseAttributes: attributes,
@@ -29,6 +29,11 @@ func NewStatusResponse(log *log.Logger, attributes ResponseAttributes, responseC
 	}
 }
 
+// IsErroneousResponse reports whether the request failed with an HTTP error or without any response
+func (s *StatusResponse) IsErroneousResponse() bool {
+	return s.ResponseCode >= http.StatusBadRequest || s.ResponseCode < 0
+}
+
 func (s *StatusResponse) GetRetryAfter() time.Duration {
 
 	h := s.Headers.Get(RESPONSE_KEY_RETRY_AFTER)
@@ -0,0 +1,39 @@
+package providers
+
+import (
+	"time"
+)
+
+// Clock is the source of time for everything OpenKit schedules or timestamps
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type defaultClock struct{}
+
+// NewDefaultClock returns a Clock backed by the time package
+func NewDefaultClock() Clock {
+	return defaultClock{}
+}
+
+func (defaultClock) Now() time.Time                         { return time.Now() }
+func (defaultClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (defaultClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (defaultClock) NewTimer(d time.Duration) Timer         { return &defaultTimer{time.NewTimer(d)} }
+
+type defaultTimer struct {
+	timer *time.Timer
+}
+
+func (t *defaultTimer) C() <-chan time.Time        { return t.timer.C }
+func (t *defaultTimer) Stop() bool                 { return t.timer.Stop() }
+func (t *defaultTimer) Reset(d time.Duration) bool { return t.timer.Reset(d) }
@@ -0,0 +1,121 @@
+// Package providerstest provides fake providers for tests
+package providerstest
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/providers"
+	"sync"
+	"time"
+)
+
+// FakeClock is a providers.Clock that only moves when Advance or Set is called
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) providers.Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward and fires all timers that expired on the way
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to now and fires all timers that expired on the way
+func (c *FakeClock) Set(now time.Time) {
+	c.mutex.Lock()
+	c.now = now
+
+	var pending []*fakeTimer
+	for _, t := range c.timers {
+		if t.deadline.After(now) {
+			pending = append(pending, t)
+			continue
+		}
+		select {
+		case t.c <- now:
+		default:
+		}
+	}
+	c.timers = pending
+	c.mutex.Unlock()
+}
+
+// BlockUntil waits until at least n timers are pending, so a test can be sure that a goroutine went to sleep
+// before it advances the clock
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mutex.Lock()
+		pending := len(c.timers)
+		c.mutex.Unlock()
+		if pending >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (c *FakeClock) removeTimer(t *fakeTimer) bool {
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	return t.clock.removeTimer(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	wasPending := t.clock.removeTimer(t)
+	t.deadline = t.clock.now.Add(d)
+	if d <= 0 {
+		select {
+		case t.c <- t.clock.now:
+		default:
+		}
+		return wasPending
+	}
+	t.clock.timers = append(t.clock.timers, t)
+	return wasPending
+}
@@ -0,0 +1,33 @@
+package providerstest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFakeClockFiresTimers(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	short := clock.NewTimer(time.Second)
+	long := clock.NewTimer(time.Minute)
+	stopped := clock.NewTimer(time.Second)
+	assert.True(t, stopped.Stop())
+
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, start.Add(2*time.Second), clock.Now())
+	assert.Equal(t, start.Add(2*time.Second), <-short.C())
+	assert.Empty(t, long.C())
+	assert.Empty(t, stopped.C())
+
+	done := make(chan struct{})
+	go func() {
+		clock.Sleep(time.Hour)
+		close(done)
+	}()
+	clock.BlockUntil(2)
+	clock.Advance(time.Hour)
+	<-done
+	assert.Equal(t, start.Add(2*time.Second+time.Hour), <-long.C())
+}
@@ -0,0 +1,41 @@
+package providers
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RandomNumberGenerator is the source of randomness for session IDs and traffic control sampling
+type RandomNumberGenerator interface {
+	NextPositiveInt64() int64
+	// NextPercentageValue returns a value in [0, 100)
+	NextPercentageValue() int
+}
+
+type defaultRandomNumberGenerator struct {
+	random *rand.Rand
+	mutex  sync.Mutex
+}
+
+// NewRandomNumberGenerator returns a RandomNumberGenerator drawing from source, it is safe for concurrent use
+func NewRandomNumberGenerator(source rand.Source) RandomNumberGenerator {
+	return &defaultRandomNumberGenerator{random: rand.New(source)}
+}
+
+// NewDefaultRandomNumberGenerator returns a RandomNumberGenerator seeded with the current time
+func NewDefaultRandomNumberGenerator() RandomNumberGenerator {
+	return NewRandomNumberGenerator(rand.NewSource(time.Now().UnixNano()))
+}
+
+func (r *defaultRandomNumberGenerator) NextPositiveInt64() int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.random.Int63()
+}
+
+func (r *defaultRandomNumberGenerator) NextPercentageValue() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.random.Intn(100)
+}
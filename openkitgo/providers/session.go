@@ -1,22 +1,69 @@
 package providers
 
 import (
-	"math/rand"
+	"math"
 	"sync"
 )
 
-type SessionIDProvider struct {
+// SessionIDProvider hands out the session numbers sent with each beacon
+type SessionIDProvider interface {
+	GetNextSessionID() int32
+}
+
+type defaultSessionIDProvider struct {
 	initialOffset int32
-	mutex         sync.RWMutex
+	mutex         sync.Mutex
 }
 
-func NewSessionIDProvider() *SessionIDProvider {
-	return &SessionIDProvider{initialOffset: rand.Int31()}
+// NewSessionIDProvider returns a SessionIDProvider counting upwards from a random offset taken from random
+func NewSessionIDProvider(random RandomNumberGenerator) SessionIDProvider {
+	return &defaultSessionIDProvider{initialOffset: int32(random.NextPositiveInt64() % math.MaxInt32)}
 }
 
-func (p *SessionIDProvider) GetNextSessionID() int32 {
+func (p *defaultSessionIDProvider) GetNextSessionID() int32 {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	if p.initialOffset == math.MaxInt32 {
+		p.initialOffset = 0
+	}
 	p.initialOffset += 1
 	return p.initialOffset
 }
+
+type fixedSessionIDProvider struct {
+	sessionID int32
+}
+
+// NewFixedSessionIDProvider takes a single session ID from provider and always returns it,
+// split sessions of one SessionProxy use it to keep their session number
+func NewFixedSessionIDProvider(provider SessionIDProvider) SessionIDProvider {
+	return &fixedSessionIDProvider{sessionID: provider.GetNextSessionID()}
+}
+
+func (p *fixedSessionIDProvider) GetNextSessionID() int32 {
+	return p.sessionID
+}
+
+type skippingSessionIDProvider struct {
+	provider SessionIDProvider
+	skip     map[int32]bool
+}
+
+// NewSkippingSessionIDProvider returns the IDs of provider except those in skip, like the session numbers of
+// sessions recovered from a persistent cache. It gives up after len(skip) reserved IDs in a row, so a provider
+// that always returns the same ID does not block.
+func NewSkippingSessionIDProvider(provider SessionIDProvider, skip []int32) SessionIDProvider {
+	p := &skippingSessionIDProvider{provider: provider, skip: make(map[int32]bool, len(skip))}
+	for _, id := range skip {
+		p.skip[id] = true
+	}
+	return p
+}
+
+func (p *skippingSessionIDProvider) GetNextSessionID() int32 {
+	id := p.provider.GetNextSessionID()
+	for i := 0; i < len(p.skip) && p.skip[id]; i++ {
+		id = p.provider.GetNextSessionID()
+	}
+	return id
+}
@@ -0,0 +1,43 @@
+package providers
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSessionIDProviderIsReproducible(t *testing.T) {
+	p1 := NewSessionIDProvider(NewRandomNumberGenerator(rand.NewSource(42)))
+	p2 := NewSessionIDProvider(NewRandomNumberGenerator(rand.NewSource(42)))
+
+	first := p1.GetNextSessionID()
+	assert.Equal(t, first, p2.GetNextSessionID())
+	assert.Equal(t, first+1, p1.GetNextSessionID())
+}
+
+func TestSessionIDProviderWrapsAround(t *testing.T) {
+	p := &defaultSessionIDProvider{initialOffset: math.MaxInt32 - 1}
+
+	assert.Equal(t, int32(math.MaxInt32), p.GetNextSessionID())
+	assert.Equal(t, int32(1), p.GetNextSessionID())
+}
+
+func TestFixedSessionIDProvider(t *testing.T) {
+	p := &defaultSessionIDProvider{initialOffset: 10}
+	fixed := NewFixedSessionIDProvider(p)
+
+	assert.Equal(t, int32(11), fixed.GetNextSessionID())
+	assert.Equal(t, int32(11), fixed.GetNextSessionID())
+	assert.Equal(t, int32(12), p.GetNextSessionID())
+}
+
+func TestSkippingSessionIDProvider(t *testing.T) {
+	p := NewSkippingSessionIDProvider(&defaultSessionIDProvider{initialOffset: 10}, []int32{11, 12, 14})
+
+	assert.Equal(t, int32(13), p.GetNextSessionID())
+	assert.Equal(t, int32(15), p.GetNextSessionID())
+
+	fixed := NewSkippingSessionIDProvider(NewFixedSessionIDProvider(&defaultSessionIDProvider{initialOffset: 10}), []int32{11})
+	assert.Equal(t, int32(11), fixed.GetNextSessionID(), "gives up instead of blocking")
+}
@@ -0,0 +1,20 @@
+package providers
+
+// ThreadIDProvider returns the thread ID reported with every beacon
+type ThreadIDProvider interface {
+	GetThreadID() int32
+}
+
+type fixedThreadIDProvider struct {
+	threadID int32
+}
+
+// NewFixedThreadIDProvider returns a ThreadIDProvider that always reports threadID,
+// goroutines have no stable identity so OpenKit reports 1 by default
+func NewFixedThreadIDProvider(threadID int32) ThreadIDProvider {
+	return fixedThreadIDProvider{threadID: threadID}
+}
+
+func (p fixedThreadIDProvider) GetThreadID() int32 {
+	return p.threadID
+}
@@ -0,0 +1,18 @@
+package redaction
+
+import (
+	"net"
+)
+
+// AnonymizeIP zeroes the last octet of IPv4 addresses and the last 80 bits of IPv6 addresses.
+// Values that are not IP addresses are returned unchanged.
+func AnonymizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,122 @@
+// Package redaction removes personal data and secrets from reported data before it is cached and sent
+package redaction
+
+import (
+	"sync/atomic"
+)
+
+// Field is the kind of reported data a rule is applied to
+type Field int
+
+const (
+	FIELD_ACTION_NAME Field = iota
+	FIELD_EVENT_NAME
+	FIELD_VALUE_NAME
+	FIELD_VALUE
+	FIELD_URL
+	FIELD_ERROR_NAME
+	FIELD_ERROR_REASON
+	FIELD_STACKTRACE
+	FIELD_USER_TAG
+
+	numFields
+)
+
+var fieldNames = [numFields]string{
+	"actionName", "eventName", "valueName", "value", "url", "errorName", "errorReason", "stacktrace", "userTag",
+}
+
+func (f Field) String() string {
+	if f < 0 || f >= numFields {
+		return "unknown"
+	}
+	return fieldNames[f]
+}
+
+// Rule redacts a single value, it returns the new value and how many parts of it were redacted
+type Rule interface {
+	Redact(field Field, value string) (string, int)
+}
+
+// Redactor applies its rules in order to every reported field and counts the redactions.
+// A nil Redactor leaves all data unchanged.
+type Redactor struct {
+	rules             []Rule
+	anonymizeClientIP bool
+
+	redactions          [numFields]int64 // atomic
+	clientIPsAnonymized int64            // atomic
+}
+
+func NewRedactor(rules ...Rule) *Redactor {
+	return &Redactor{rules: rules}
+}
+
+// NewDefaultRedactor returns a Redactor with DefaultRules that also anonymises client IP addresses
+func NewDefaultRedactor() *Redactor {
+	return NewRedactor(DefaultRules()...).WithClientIPAnonymization()
+}
+
+// WithClientIPAnonymization makes RedactClientIP zero the host part of client IP addresses, see AnonymizeIP
+func (r *Redactor) WithClientIPAnonymization() *Redactor {
+	r.anonymizeClientIP = true
+	return r
+}
+
+func (r *Redactor) Redact(field Field, value string) string {
+	if r == nil || value == "" {
+		return value
+	}
+	total := 0
+	for _, rule := range r.rules {
+		var n int
+		value, n = rule.Redact(field, value)
+		total += n
+	}
+	if total > 0 && field >= 0 && field < numFields {
+		atomic.AddInt64(&r.redactions[field], int64(total))
+	}
+	return value
+}
+
+func (r *Redactor) RedactClientIP(ip string) string {
+	if r == nil || !r.anonymizeClientIP || ip == "" {
+		return ip
+	}
+	anonymized := AnonymizeIP(ip)
+	if anonymized != ip {
+		atomic.AddInt64(&r.clientIPsAnonymized, 1)
+	}
+	return anonymized
+}
+
+// Stats are the redactions made since the Redactor was created
+type Stats struct {
+	Redactions          map[Field]int64
+	ClientIPsAnonymized int64
+}
+
+// Total is the number of redactions over all fields
+func (s Stats) Total() int64 {
+	total := int64(0)
+	for _, n := range s.Redactions {
+		total += n
+	}
+	return total
+}
+
+func (r *Redactor) Stats() Stats {
+	if r == nil {
+		return Stats{Redactions: map[Field]int64{}}
+	}
+	stats := Stats{
+		Redactions:          map[Field]int64{},
+		ClientIPsAnonymized: atomic.LoadInt64(&r.clientIPsAnonymized),
+	}
+	for field := Field(0); field < numFields; field++ {
+		if n := atomic.LoadInt64(&r.redactions[field]); n > 0 {
+			stats.Redactions[field] = n
+		}
+	}
+	return stats
+}
@@ -0,0 +1,147 @@
+package redaction
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const REDACTED = "[REDACTED]"
+
+// appliesTo reports whether a rule restricted to fields applies to field, no fields means all fields
+func appliesTo(fields []Field, field Field) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+type regexRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+	fields      []Field
+}
+
+// RegexRule replaces all matches of pattern with replacement, which may refer to submatches like $1.
+// It applies to the given fields, or to all fields if none are given.
+func RegexRule(pattern *regexp.Regexp, replacement string, fields ...Field) Rule {
+	return &regexRule{pattern: pattern, replacement: replacement, fields: fields}
+}
+
+func (r *regexRule) Redact(field Field, value string) (string, int) {
+	if !appliesTo(r.fields, field) {
+		return value, 0
+	}
+	matches := r.pattern.FindAllStringIndex(value, -1)
+	if len(matches) == 0 {
+		return value, 0
+	}
+	return r.pattern.ReplaceAllString(value, r.replacement), len(matches)
+}
+
+type funcRule struct {
+	redact func(value string) string
+	fields []Field
+}
+
+// FuncRule replaces the values of the given fields with the result of redact
+func FuncRule(redact func(value string) string, fields ...Field) Rule {
+	return &funcRule{redact: redact, fields: fields}
+}
+
+func (r *funcRule) Redact(field Field, value string) (string, int) {
+	if !appliesTo(r.fields, field) {
+		return value, 0
+	}
+	redacted := r.redact(value)
+	if redacted == value {
+		return value, 0
+	}
+	return redacted, 1
+}
+
+// DropFieldRule replaces the whole value of the given fields with REDACTED
+func DropFieldRule(fields ...Field) Rule {
+	return FuncRule(func(string) string { return REDACTED }, fields...)
+}
+
+var (
+	emailPattern        = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	bearerTokenPattern  = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]+=*`)
+	jwtPattern          = regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+	awsAccessKeyPattern = regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)
+)
+
+// DEFAULT_SECRET_PARAMETERS are the query parameters whose values QuerySecretsRule redacts
+var DEFAULT_SECRET_PARAMETERS = []string{
+	"access_token", "api_key", "apikey", "auth", "client_secret", "code", "key", "password", "passwd", "pwd",
+	"refresh_token", "secret", "session", "sessionid", "sig", "signature", "token",
+}
+
+// EmailRule redacts e-mail addresses in all fields
+func EmailRule() Rule {
+	return RegexRule(emailPattern, REDACTED)
+}
+
+// BearerTokenRule redacts credentials of bearer and basic authorization headers in all fields
+func BearerTokenRule() Rule {
+	return RegexRule(bearerTokenPattern, "$1 "+REDACTED)
+}
+
+// JWTRule redacts JSON web tokens in all fields
+func JWTRule() Rule {
+	return RegexRule(jwtPattern, REDACTED)
+}
+
+// AWSAccessKeyRule redacts AWS access key IDs in all fields
+func AWSAccessKeyRule() Rule {
+	return RegexRule(awsAccessKeyPattern, REDACTED)
+}
+
+type querySecretsRule struct {
+	pattern *regexp.Regexp
+}
+
+// QuerySecretsRule redacts the values of the given query parameters, matched case insensitively,
+// wherever a name=value pair appears. Without parameters DEFAULT_SECRET_PARAMETERS are used.
+func QuerySecretsRule(parameters ...string) Rule {
+	if len(parameters) == 0 {
+		parameters = DEFAULT_SECRET_PARAMETERS
+	}
+	quoted := make([]string, len(parameters))
+	for i, parameter := range parameters {
+		quoted[i] = regexp.QuoteMeta(parameter)
+	}
+	pattern := regexp.MustCompile(`(?i)([?&;\s]|^)(` + strings.Join(quoted, "|") + `)=([^&#;\s]*)`)
+	return &querySecretsRule{pattern: pattern}
+}
+
+func (r *querySecretsRule) Redact(field Field, value string) (string, int) {
+	n := 0
+	redacted := r.pattern.ReplaceAllStringFunc(value, func(match string) string {
+		submatches := r.pattern.FindStringSubmatch(match)
+		if submatches[3] == "" || submatches[3] == url.QueryEscape(REDACTED) {
+			return match
+		}
+		n++
+		return submatches[1] + submatches[2] + "=" + url.QueryEscape(REDACTED)
+	})
+	return redacted, n
+}
+
+// DefaultRules redact e-mail addresses, bearer and basic credentials, JSON web tokens, AWS access keys and
+// secrets in query strings
+func DefaultRules() []Rule {
+	return []Rule{
+		JWTRule(),
+		BearerTokenRule(),
+		AWSAccessKeyRule(),
+		QuerySecretsRule(),
+		EmailRule(),
+	}
+}
@@ -0,0 +1,110 @@
+// Package tracing provides an http.RoundTripper that reports outgoing requests as OpenKit web requests
+package tracing
+
+import (
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/core"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Transport traces every request made through it on the action or session carried by the request context
+// and adds the X-dynaTrace tag header, so the request can be correlated on the server side
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tracer := openkitgo.TraceWebRequestContext(req.Context(), req.URL.String())
+	if _, ok := tracer.(*core.NullWebRequestTracer); ok {
+		return t.base().RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request it was given
+	r := req.Clone(req.Context())
+	if tag := tracer.GetTag(); tag != "" {
+		r.Header.Set(core.WEBREQUEST_TAG_HEADER, tag)
+	}
+
+	var sent *countingReadCloser
+	if req.Body != nil && req.Body != http.NoBody {
+		sent = &countingReadCloser{ReadCloser: req.Body}
+		r.Body = sent
+	}
+
+	tracer.Start()
+	resp, err := t.base().RoundTrip(r)
+	if err != nil {
+		setBytesSent(tracer, sent, req.ContentLength)
+		tracer.Stop(0)
+		return resp, err
+	}
+
+	resp.Body = &tracedBody{
+		countingReadCloser: countingReadCloser{ReadCloser: resp.Body},
+		tracer:             tracer,
+		responseCode:       resp.StatusCode,
+		sent:               sent,
+		contentLength:      req.ContentLength,
+	}
+	return resp, nil
+}
+
+// setBytesSent reports the bytes read from the request body, or contentLength for requests without a body
+func setBytesSent(tracer interfaces.WebRequestTracer, sent *countingReadCloser, contentLength int64) {
+	if sent != nil {
+		tracer.SetBytesSent(int(sent.count()))
+	} else if contentLength >= 0 {
+		tracer.SetBytesSent(int(contentLength))
+	}
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64 // atomic
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+func (c *countingReadCloser) count() int64 {
+	return atomic.LoadInt64(&c.n)
+}
+
+// tracedBody stops the web request tracer once the caller is done with the response body. The bytes sent are
+// counted until then as well, the transport may still write a streamed request body after RoundTrip returned.
+type tracedBody struct {
+	countingReadCloser
+	tracer        interfaces.WebRequestTracer
+	responseCode  int
+	sent          *countingReadCloser
+	contentLength int64
+	once          sync.Once
+}
+
+func (b *tracedBody) Close() error {
+	err := b.countingReadCloser.Close()
+	b.once.Do(func() {
+		setBytesSent(b.tracer, b.sent, b.contentLength)
+		b.tracer.SetBytesReceived(int(b.count()))
+		b.tracer.Stop(b.responseCode)
+	})
+	return err
+}
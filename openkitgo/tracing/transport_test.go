@@ -0,0 +1,160 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/core"
+	"github.com/dlopes7/dynatrace-openkit-go/openkitgo/interfaces"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeTracer struct {
+	url           string
+	started       bool
+	stopped       int
+	responseCode  int
+	bytesSent     int
+	bytesReceived int
+}
+
+func (f *fakeTracer) GetTag() string { return "MT_3_1_42" }
+func (f *fakeTracer) SetBytesSent(bytesSent int) interfaces.WebRequestTracer {
+	f.bytesSent = bytesSent
+	return f
+}
+func (f *fakeTracer) SetBytesReceived(bytesReceived int) interfaces.WebRequestTracer {
+	f.bytesReceived = bytesReceived
+	return f
+}
+func (f *fakeTracer) Start() interfaces.WebRequestTracer                      { f.started = true; return f }
+func (f *fakeTracer) StartAt(timestamp time.Time) interfaces.WebRequestTracer { return f.Start() }
+func (f *fakeTracer) Stop(responseCode int) {
+	f.stopped++
+	f.responseCode = responseCode
+}
+func (f *fakeTracer) StopAt(responseCode int, timestamp time.Time) { f.Stop(responseCode) }
+
+type fakeAction struct {
+	interfaces.Action
+	tracer *fakeTracer
+}
+
+func (a *fakeAction) TraceWebRequest(url string) interfaces.WebRequestTracer {
+	a.tracer = &fakeTracer{url: url}
+	return a.tracer
+}
+
+func TestTransportTracesRequest(t *testing.T) {
+	var receivedTag string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedTag = r.Header.Get(core.WEBREQUEST_TAG_HEADER)
+		body, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	action := &fakeAction{}
+	client := &http.Client{Transport: NewTransport(nil)}
+
+	req, _ := http.NewRequest("POST", server.URL+"/items", strings.NewReader("hello"))
+	req = req.WithContext(openkitgo.ContextWithAction(context.Background(), action))
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "MT_3_1_42", receivedTag)
+	assert.Empty(t, req.Header.Get(core.WEBREQUEST_TAG_HEADER))
+	assert.Equal(t, server.URL+"/items", action.tracer.url)
+	assert.True(t, action.tracer.started)
+	assert.Equal(t, 0, action.tracer.stopped)
+
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body.Close()
+
+	assert.Equal(t, 1, action.tracer.stopped)
+	assert.Equal(t, http.StatusCreated, action.tracer.responseCode)
+	assert.Equal(t, 5, action.tracer.bytesSent)
+	assert.Equal(t, 5, action.tracer.bytesReceived)
+}
+
+// streamingRoundTripper responds right away and reads the request body afterwards, like a transport that streams
+// a request body while the response is already being read
+type streamingRoundTripper struct {
+	bodyRead chan struct{}
+}
+
+func (s streamingRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	go func() {
+		ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		close(s.bodyRead)
+	}()
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("ok"))}, nil
+}
+
+func TestTransportCountsBytesSentUntilResponseIsClosed(t *testing.T) {
+	action := &fakeAction{}
+	base := streamingRoundTripper{bodyRead: make(chan struct{})}
+	client := &http.Client{Transport: NewTransport(base)}
+
+	body, writer := io.Pipe()
+	go func() {
+		writer.Write([]byte("streamed "))
+		writer.Write([]byte("body"))
+		writer.Close()
+	}()
+	req, _ := http.NewRequest("POST", "http://localhost/upload", body)
+	req = req.WithContext(openkitgo.ContextWithAction(context.Background(), action))
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+
+	<-base.bodyRead
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, 13, action.tracer.bytesSent)
+	assert.Equal(t, 2, action.tracer.bytesReceived)
+}
+
+type failingRoundTripper struct{}
+
+func (failingRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestTransportStopsTracerOnError(t *testing.T) {
+	action := &fakeAction{}
+	client := &http.Client{Transport: NewTransport(failingRoundTripper{})}
+
+	req, _ := http.NewRequest("GET", "http://localhost/", nil)
+	req = req.WithContext(openkitgo.ContextWithAction(context.Background(), action))
+	_, err := client.Do(req)
+	assert.Error(t, err)
+
+	assert.Equal(t, 1, action.tracer.stopped)
+	assert.Equal(t, 0, action.tracer.responseCode)
+}
+
+func TestTransportWithoutActionIsPassThrough(t *testing.T) {
+	var receivedTag string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedTag = r.Header.Get(core.WEBREQUEST_TAG_HEADER)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil)}
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Empty(t, receivedTag)
+}